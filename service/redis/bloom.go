@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// MaxBloomBits is the maximal size of a BloomFilter, it is the limit of the bit offset of SETBIT (512MB).
+const MaxBloomBits = 1 << 32
+
+// ScriptBloomAdd sets the bits of an item, it returns 1 if any of them was 0, i.e. the item is new.
+// KEYS: bitmap
+// ARGV: bit offsets...
+const ScriptBloomAdd = `
+	local added = 0
+	for i = 1, #ARGV do
+		if redis.call("SETBIT", KEYS[1], ARGV[i], 1) == 0 then
+			added = 1
+		end
+	end
+	return added
+`
+
+var scriptBloomAdd = RegisterScript("bloom.add", 1, ScriptBloomAdd)
+
+var errInvalidBloom = errors.New("redis: bloom filter needs positive capacity and false positive rate in (0, 1)")
+
+// BloomFilter is a Bloom filter stored in a redis string by SETBIT and GETBIT, it works without the RedisBloom module.
+// An added item is always reported to exist, an item never added is reported to exist with the false positive rate.
+type BloomFilter struct {
+	pool *Pool
+	key  string
+	// m is the number of bits, k is the number of hash functions
+	m uint64
+	k uint64
+}
+
+// NewBloomFilter returns a BloomFilter sized for n items with the false positive rate fpRate,
+// the same n and fpRate must be used by all clients of the key.
+func NewBloomFilter(pool *Pool, key string, n uint64, fpRate float64) (*BloomFilter, error) {
+	m, k, err := BloomParams(n, fpRate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BloomFilter{pool: pool, key: key, m: m, k: k}, nil
+}
+
+// BloomParams returns the optimal number of bits m and hash functions k for n items with the false positive rate fpRate.
+func BloomParams(n uint64, fpRate float64) (m, k uint64, err error) {
+	if n == 0 || fpRate <= 0 || fpRate >= 1 {
+		return 0, 0, errInvalidBloom
+	}
+
+	// m = -n*ln(p)/ln(2)^2, k = m/n*ln(2)
+	bits := math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	if bits > MaxBloomBits {
+		return 0, 0, errors.New("redis: bloom filter exceeds MaxBloomBits")
+	}
+
+	m = uint64(bits)
+	k = uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+
+	return m, k, nil
+}
+
+// BloomFalsePositiveRate returns the expected false positive rate of m bits and k hash functions after n items are added.
+func BloomFalsePositiveRate(m, k, n uint64) float64 {
+	return math.Pow(1-math.Exp(-float64(k)*float64(n)/float64(m)), float64(k))
+}
+
+func (b *BloomFilter) Key() string {
+	return b.key
+}
+
+// M returns the number of bits.
+func (b *BloomFilter) M() uint64 {
+	return b.m
+}
+
+// K returns the number of hash functions.
+func (b *BloomFilter) K() uint64 {
+	return b.k
+}
+
+// Add adds the item, it reports whether the item is new, i.e. it was not reported to exist before.
+// The bits are set in one script, so only one of the concurrent adds of an item reports it new.
+func (b *BloomFilter) Add(item []byte) (bool, error) {
+	added, err := redis.Int(b.pool.EvalScript(scriptBloomAdd, redis.Args{}.Add(b.key).AddFlat(b.offsets(item))...))
+	if err != nil {
+		return false, err
+	}
+
+	return added == 1, nil
+}
+
+// Exists reports whether the item may have been added.
+func (b *BloomFilter) Exists(item []byte) (bool, error) {
+	pl := b.pool.Pipeline()
+	for _, offset := range b.offsets(item) {
+		pl.Send("GETBIT", b.key, offset)
+	}
+
+	cmds, err := pl.Exec()
+	if err != nil {
+		return false, err
+	}
+
+	for _, cmd := range cmds {
+		bit, err := cmd.Int64()
+		if err != nil {
+			return false, err
+		}
+		if bit == 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Clear deletes all items of the filter.
+func (b *BloomFilter) Clear() error {
+	return b.pool.Del(b.key)
+}
+
+// offsets returns the k bit offsets of the item by double hashing: h1 + i*h2 with the halves of FNV-1a 128.
+func (b *BloomFilter) offsets(item []byte) []uint64 {
+	h := fnv.New128a()
+	_, _ = h.Write(item)
+	sum := h.Sum(nil)
+
+	var h1, h2 uint64
+	for i := 0; i < 8; i++ {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[i+8])
+	}
+	// h2 为奇数，保证 k 个位置不会因步长为 0 而重合
+	h2 |= 1
+
+	offsets := make([]uint64, b.k)
+	for i := range offsets {
+		offsets[i] = (h1 + uint64(i)*h2) % b.m
+	}
+
+	return offsets
+}
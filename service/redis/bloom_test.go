@@ -0,0 +1,117 @@
+package redis
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomParams(t *testing.T) {
+	testCases := []struct {
+		name   string
+		n      uint64
+		fpRate float64
+		wantM  uint64
+		wantK  uint64
+	}{
+		{name: "1%", n: 1000, fpRate: 0.01, wantM: 9586, wantK: 7},
+		{name: "0.1%", n: 1000000, fpRate: 0.001, wantM: 14377588, wantK: 10},
+		{name: "loose", n: 10, fpRate: 0.9, wantM: 3, wantK: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, k, err := BloomParams(tc.n, tc.fpRate)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantM, m)
+			assert.Equal(t, tc.wantK, k)
+			assert.InDelta(t, tc.fpRate, BloomFalsePositiveRate(m, k, tc.n), tc.fpRate*0.2)
+		})
+	}
+
+	for _, fpRate := range []float64{0, 1} {
+		_, _, err := BloomParams(1000, fpRate)
+		assert.Equal(t, errInvalidBloom, err)
+	}
+	_, _, err := BloomParams(1<<40, 0.0001)
+	assert.Error(t, err)
+}
+
+func TestBloomFilter_Offsets(t *testing.T) {
+	b, err := NewBloomFilter(nil, "bloom", 1000, 0.01)
+	assert.NoError(t, err)
+
+	// 在本地位图上模拟 SETBIT/GETBIT，验证误判率接近配置值
+	bits := make([]bool, b.M())
+	for i := 0; i < 1000; i++ {
+		offsets := b.offsets([]byte("item-" + strconv.Itoa(i)))
+		assert.Len(t, offsets, int(b.K()))
+		assert.Equal(t, offsets, b.offsets([]byte("item-"+strconv.Itoa(i))))
+		for _, offset := range offsets {
+			bits[offset] = true
+		}
+	}
+
+	var falsePositives int
+	for i := 0; i < 10000; i++ {
+		exists := true
+		for _, offset := range b.offsets([]byte("other-" + strconv.Itoa(i))) {
+			exists = exists && bits[offset]
+		}
+		if exists {
+			falsePositives++
+		}
+	}
+	assert.Less(t, float64(falsePositives)/10000, 0.02)
+}
+
+func TestBloomFilter(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+	b, err := NewBloomFilter(p, "bloom", 1000, 0.01)
+	assert.NoError(t, err)
+
+	exists, err := b.Exists([]byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	added, err := b.Add([]byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, added)
+	added, err = b.Add([]byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, added)
+
+	exists, err = b.Exists([]byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.NoError(t, b.Clear())
+	exists, err = b.Exists([]byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestBloomFilter_AddConcurrent(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+	b, err := NewBloomFilter(p, "bloom", 1000, 0.01)
+	assert.NoError(t, err)
+
+	// 并发添加同一元素，只有一次报告为新元素
+	var wg sync.WaitGroup
+	var added atomic.Int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, err := b.Add([]byte("item")); err == nil && ok {
+				added.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), added.Load())
+}
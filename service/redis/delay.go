@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const delayedSuffix = "_delayed"
+
+// 延时队列使用有序集合 <queue>_delayed 存储 key，score 为到期时间（毫秒），数据存储在 <queue>_delayed_htable，
+// 到期后原子地移动到原有队列（list 存 key，<queue>_htable 存数据）中。
+const (
+	// ScriptDelayEnQueue adds the key to the delayed sorted set, the due time and data of an existing key are overwritten.
+	// KEYS: delayed zset, delayed htable
+	// ARGV: due time in milliseconds, key, data
+	ScriptDelayEnQueue = `
+		redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+		redis.call("HSET", KEYS[2], ARGV[2], ARGV[3])
+		return 1
+	`
+	// ScriptPromoteDelayed moves the due keys to the queue, keys already in the queue are merged like EnQueue.
+	// KEYS: queue, queue htable, delayed zset, delayed htable
+	// ARGV: now in milliseconds, limit
+	ScriptPromoteDelayed = `
+		local due = redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+		for _, key in ipairs(due) do
+			local data = redis.call("HGET", KEYS[4], key)
+			redis.call("HDEL", KEYS[4], key)
+			redis.call("ZREM", KEYS[3], key)
+			if data and redis.call("HSET", KEYS[2], key, data) == 1 then
+				redis.call("RPUSH", KEYS[1], key)
+			end
+		end
+		return #due
+	`
+)
+
+var (
+	scriptDelayEnQueue   = RegisterScript("queue.delay_enqueue", 2, ScriptDelayEnQueue)
+	scriptPromoteDelayed = RegisterScript("queue.promote_delayed", 4, ScriptPromoteDelayed)
+)
+
+// EnQueueAt adds a job which is moved to the queue at the given time by PromoteDelayed,
+// enqueuing a delayed key again overwrites its due time and data.
+// The due time is converted to the clock of the redis server which PromoteDelayed compares with,
+// so the skew of the local clock does not shift it.
+func EnQueueAt(pool *Pool, queueName, key string, data []byte, at time.Time) error {
+	return EnQueueAfter(pool, queueName, key, data, time.Until(at))
+}
+
+// EnQueueAfter adds a job which is moved to the queue after delay, the delay is based on the redis server time.
+func EnQueueAfter(pool *Pool, queueName, key string, data []byte, delay time.Duration) error {
+	if pool == nil {
+		return fmt.Errorf("redis pool is empty")
+	}
+
+	if key == "" {
+		return fmt.Errorf("queue %s using invalid key: %s", queueName, key)
+	}
+
+	now, err := pool.ServerTime()
+	if err != nil {
+		return err
+	}
+
+	delayed := queueName + delayedSuffix
+	_, err = pool.EvalScript(scriptDelayEnQueue, delayed, delayed+hTable, now.Add(delay).UnixMilli(), key, data)
+
+	return err
+}
+
+// PromoteDelayed moves at most limit due jobs to the queue, it returns the number of due jobs.
+func PromoteDelayed(pool *Pool, queueName string, limit int) (int, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("redis pool is empty")
+	}
+
+	now, err := pool.ServerTime()
+	if err != nil {
+		return 0, err
+	}
+
+	delayed := queueName + delayedSuffix
+
+	return redis.Int(pool.EvalScript(scriptPromoteDelayed,
+		queueName, queueName+hTable, delayed, delayed+hTable, now.UnixMilli(), limit))
+}
+
+// RunDelayedMover calls PromoteDelayed and PromoteRetries every interval until ctx is done.
+func RunDelayedMover(ctx context.Context, pool *Pool, queueName string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// 每次最多处理 100 个，处理满时立即继续
+		for _, promote := range []func(*Pool, string, int) (int, error){PromoteDelayed, PromoteRetries} {
+			for {
+				n, err := promote(pool, queueName, 100)
+				if err != nil || n < 100 || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnQueueAfter(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	now := time.Now().Truncate(time.Millisecond)
+	s.SetTime(now)
+
+	assert.NoError(t, EnQueueAfter(p, "queue", "a", []byte("1"), time.Minute))
+	assert.NoError(t, EnQueueAfter(p, "queue", "b", []byte("2"), 2*time.Minute))
+
+	n, err := PromoteDelayed(p, "queue", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.False(t, s.Exists("queue"))
+
+	// 到期的任务移动到队列，未到期的保留
+	s.SetTime(now.Add(time.Minute))
+	n, err = PromoteDelayed(p, "queue", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	key, data := DeQueue(p, "queue")
+	assert.Equal(t, "a", key)
+	assert.Equal(t, []byte("1"), data)
+
+	members, err := s.ZMembers("queue" + delayedSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, members)
+	assert.Equal(t, "2", s.HGet("queue"+delayedSuffix+hTable, "b"))
+
+	// 再次入队覆盖到期时间及数据
+	assert.NoError(t, EnQueueAfter(p, "queue", "b", []byte("3"), 0))
+	n, err = PromoteDelayed(p, "queue", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	key, data = DeQueue(p, "queue")
+	assert.Equal(t, "b", key)
+	assert.Equal(t, []byte("3"), data)
+	assert.False(t, s.Exists("queue"+delayedSuffix))
+}
+
+func TestEnQueueAtServerTime(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	// redis 服务器时钟比本地快 1 小时，到期时间按服务器时钟计算
+	serverNow := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	s.SetTime(serverNow)
+
+	assert.NoError(t, EnQueueAt(p, "queue", "a", []byte("1"), time.Now().Add(time.Minute)))
+	score, err := s.ZScore("queue"+delayedSuffix, "a")
+	assert.NoError(t, err)
+	due := time.UnixMilli(int64(score))
+	assert.WithinDuration(t, serverNow.Add(time.Minute), due, time.Second)
+
+	n, err := PromoteDelayed(p, "queue", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestPromoteDelayedMerge(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	// 队列中已有的 key 合并数据，不重复入队
+	assert.NoError(t, EnQueue(p, "queue", "a", []byte("old")))
+	assert.NoError(t, EnQueueAfter(p, "queue", "a", []byte("new"), -time.Second))
+
+	n, err := PromoteDelayed(p, "queue", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	keys, err := s.List("queue")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+	assert.Equal(t, "new", s.HGet("queue"+hTable, "a"))
+}
+
+func TestRunDelayedMover(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	assert.NoError(t, EnQueueAfter(p, "queue", "delayed", []byte("1"), 0))
+	_, err := Retry(p, "queue", "retried", []byte("2"), &RetryPolicy{MaxAttempts: 1, Backoff: ConstantBackoff(0)})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunDelayedMover(ctx, p, "queue", 10*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		keys, _ := s.List("queue")
+		return len(keys) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "1", s.HGet("queue"+hTable, "delayed"))
+	assert.Equal(t, "2", s.HGet("queue"+hTable, "retried"))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("mover not stopped")
+	}
+}
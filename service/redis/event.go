@@ -5,6 +5,9 @@ import (
 	"time"
 )
 
+// Lock 获取锁，加锁和设置超时时间是原子操作。
+//
+// Deprecated: the lock stores a constant value and can be released by anyone, use Locker instead.
 func Lock(globalPool *Pool, key string, maxRetry, expiredSecond int) error {
 	if globalPool == nil {
 		return fmt.Errorf("global redis pool is empty")
@@ -14,79 +17,73 @@ func Lock(globalPool *Pool, key string, maxRetry, expiredSecond int) error {
 	if maxRetry <= 0 {
 		maxRetry = 5
 	}
-	var i = 0
-	for ; i < maxRetry; i++ {
-		result, err := globalPool.SetNx(key, "1")
-		if err != nil {
-			return err
-		}
-		if result != 1 {
-			if maxRetry > 2 {
-				time.Sleep(100 * time.Millisecond)
-			}
-
-			continue
-		}
-
-		break
-	}
-
-	if i == maxRetry {
-		return fmt.Errorf("lock failed %s", key)
-	}
-
 	// 锁不主动释放，设置超时时间后自动释放
 	// 设置自动超时，过期回收，避免分布式锁死锁
 	if expiredSecond <= 0 {
 		expiredSecond = 3600
 	}
-	var expireHandle = func() error {
-		return globalPool.Expire(key, expiredSecond)
-	}
-	err := expireHandle()
-	if err != nil {
-		_ = expireHandle()
+	// 重试次数较少时立即重试，否则间隔 100ms
+	backoff := ConstantBackoff(100 * time.Millisecond)
+	if maxRetry <= 2 {
+		backoff = ConstantBackoff(0)
 	}
+	retry := LimitRetry(backoff, maxRetry-1)
+	for attempt := 1; ; attempt++ {
+		ok, err := globalPool.SetNxEx(key, "1", expiredSecond)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
 
-	return nil
+		wait := retry.Backoff(attempt)
+		if wait < 0 {
+			return fmt.Errorf("lock failed %s", key)
+		}
+		time.Sleep(wait)
+	}
 }
 
 // LockByExpireTime 获取锁，可以自定义重试时长、锁超时时间。
+//
+// Deprecated: use Locker instead.
 func LockByExpireTime(globalPool *Pool, key string, timeout time.Duration, expired int) error {
 	if globalPool == nil {
 		return fmt.Errorf("global redis pool is empty")
 	}
 
+	// 设置自动超时，过期回收，避免分布式锁死锁
+	if expired <= 0 {
+		expired = 3600
+	}
 	start := time.Now()
-	for {
+	backoff := ExponentialBackoff(10*time.Millisecond, 500*time.Millisecond)
+	for attempt := 1; ; attempt++ {
 		now := time.Now()
 		if now.Sub(start) > timeout {
 			return fmt.Errorf("get %s lock already expire", key)
 		}
 
-		result, err := globalPool.SetNx(key, "1")
+		ok, err := globalPool.SetNxEx(key, "1", expired)
 		if err != nil {
 			return err
 		}
-		if result != 1 {
+		if !ok {
+			// 退避等待，避免在超时前持续请求 redis
+			time.Sleep(min(backoff.Backoff(attempt), timeout-now.Sub(start)+time.Millisecond))
 			continue
 		}
 
 		break
 	}
 
-	// 设置自动超时，过期回收，避免分布式锁死锁
-	var expireHandle = func() error {
-		return globalPool.Expire(key, expired)
-	}
-	err := expireHandle()
-	if err != nil {
-		_ = expireHandle()
-	}
-
 	return nil
 }
 
+// UnLock 释放锁，不校验锁的持有者。
+//
+// Deprecated: use Mutex.Release instead.
 func UnLock(globalPool *Pool, key string) error {
 	if globalPool == nil {
 		return fmt.Errorf("global redis pool is empty")
@@ -10,22 +10,14 @@ const (
 	`
 )
 
-func (p *Pool) HSet(hTable, key, val string) (int64, error) {
-	result, err := p.Do("HSET", hTable, key, val)
-	if err != nil {
-		return 0, err
-	}
+var scriptHPop = RegisterScript("hpop", 1, ScriptHPop)
 
-	return result.(int64), nil
+func (p *Pool) HSet(hTable, key, val string) (int64, error) {
+	return redis.Int64(p.Do("HSET", hTable, key, val))
 }
 
 func (p *Pool) HSetNX(hTable, key, val string) (int64, error) {
-	result, err := p.Do("HSETNX", hTable, key, val)
-	if err != nil {
-		return 0, err
-	}
-
-	return result.(int64), nil
+	return redis.Int64(p.Do("HSETNX", hTable, key, val))
 }
 
 func (p *Pool) HGet(hTable, key string) (any, error) {
@@ -37,6 +29,26 @@ func (p *Pool) HGet(hTable, key string) (any, error) {
 	return result, nil
 }
 
+// HGetString returns the value of the field, ErrNil is returned if the field does not exist.
+func (p *Pool) HGetString(hTable, key string) (string, error) {
+	return redis.String(p.Do("HGET", hTable, key))
+}
+
+// HGetBytes returns the value of the field, ErrNil is returned if the field does not exist.
+func (p *Pool) HGetBytes(hTable, key string) ([]byte, error) {
+	return redis.Bytes(p.Do("HGET", hTable, key))
+}
+
+// HGetAll returns all fields and values of the hash, it is empty if the hash does not exist.
+func (p *Pool) HGetAll(hTable string) (map[string]string, error) {
+	return redis.StringMap(p.Do("HGETALL", hTable))
+}
+
+// HMGet returns the values of the fields in order, the value of a missing field is nil.
+func (p *Pool) HMGet(hTable string, keys ...string) ([]*string, error) {
+	return stringPtrs(p.Do("HMGET", redis.Args{}.Add(hTable).AddFlat(keys)...))
+}
+
 func (p *Pool) HLen(hTable string) (any, error) {
 	result, err := p.Do("HLEN", hTable)
 	if err != nil {
@@ -47,8 +59,7 @@ func (p *Pool) HLen(hTable string) (any, error) {
 }
 
 func (p *Pool) HPop(hTable, key string) (any, error) {
-	script := redis.NewScript(1, ScriptHPop)
-	result, err := script.Do(p.pool.Get(), hTable, key)
+	result, err := p.EvalScript(scriptHPop, hTable, key)
 	if err != nil {
 		return nil, err
 	}
@@ -56,54 +67,35 @@ func (p *Pool) HPop(hTable, key string) (any, error) {
 	return result, nil
 }
 
+// HKeys returns all fields of the hash, use HScan for big hashes since HKEYS blocks redis.
 func (p *Pool) HKeys(hTable string) ([]string, error) {
-	result, err := p.Do("HKEYS", hTable)
-	if err != nil {
-		return nil, err
-	}
-
-	elems, ok := result.([]any)
-	if !ok {
-		return nil, WrongAnswer
-	}
-
-	results := make([]string, 0, len(elems))
-	for _, elem := range elems {
-		e, ok := elem.([]byte)
-		if !ok {
-			continue
-		}
-
-		results = append(results, string(e))
-	}
-
-	return results, nil
+	return redis.Strings(p.Do("HKEYS", hTable))
 }
 
+// GetHashValues returns the values of the keys in order, the value of a missing key is empty,
+// use HMGet to tell the missing keys from the empty values.
 func (p *Pool) GetHashValues(hTable string, keys []string) ([]string, error) {
-	args := []any{hTable}
-	for _, k := range keys {
-		args = append(args, k)
-	}
+	return redis.Strings(p.Do("HMGET", redis.Args{}.Add(hTable).AddFlat(keys)...))
+}
 
-	result, err := p.Do("HMGET", args...)
+// stringPtrs converts an array reply to strings, the nil elements are kept as nil.
+func stringPtrs(reply any, err error) ([]*string, error) {
+	elems, err := redis.Values(reply, err)
 	if err != nil {
 		return nil, err
 	}
 
-	elems, ok := result.([]any)
-	if !ok {
-		return nil, WrongAnswer
-	}
-
-	results := make([]string, 0, len(elems))
-	for _, elem := range elems {
-		e, ok := elem.([]byte)
-		if !ok {
+	results := make([]*string, len(elems))
+	for i, elem := range elems {
+		if elem == nil {
 			continue
 		}
 
-		results = append(results, string(e))
+		s, err := redis.String(elem, nil)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = &s
 	}
 
 	return results, nil
@@ -0,0 +1,22 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringPtrs(t *testing.T) {
+	results, err := stringPtrs([]any{[]byte("a"), nil, []byte("")}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, "a", *results[0])
+	assert.Nil(t, results[1])
+	assert.Equal(t, "", *results[2])
+
+	_, err = stringPtrs([]any{[]any{}}, nil)
+	assert.Error(t, err)
+
+	_, err = stringPtrs(nil, ErrNil)
+	assert.ErrorIs(t, err, ErrNil)
+}
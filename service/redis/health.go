@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"sync"
+	"time"
+)
+
+// HealthCheckInterval is the interval of pinging every server of a Pool in the background.
+var HealthCheckInterval = 3 * time.Second
+
+// ServerStats is the state of a server of a Pool.
+type ServerStats struct {
+	Host string
+	// Healthy reports whether the server answered the last health check or command.
+	Healthy bool
+	// Active reports whether the server is the one in use.
+	Active bool
+	// Entropy is the count of recent failures, the Pool fails over when it reaches MaxFail.
+	Entropy int
+	// ActiveCount is the number of connections of the server, including the idle ones.
+	ActiveCount int
+	IdleCount   int
+	// LastError is the last network error of the server, nil if none.
+	LastError     error
+	LastErrorTime time.Time
+}
+
+// PoolStats is a snapshot of the state of a Pool.
+type PoolStats struct {
+	// Servers are in the order of the servers passed to NewRedisPool, unresolvable servers are skipped.
+	Servers []ServerStats
+	// Index is the index of the active server in Servers.
+	Index int
+	// FailOvers is the number of the switches of the active server.
+	FailOvers int64
+}
+
+type serverError struct {
+	err error
+	at  time.Time
+}
+
+// Stats returns the health and the connection counts of the servers.
+func (p *Pool) Stats() PoolStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := PoolStats{Index: p.index, FailOvers: p.failovers, Servers: make([]ServerStats, len(p.pools))}
+	for i, rp := range p.pools {
+		stats.Servers[i] = ServerStats{
+			Host:          p.servers[i],
+			Healthy:       p.status[i],
+			Active:        i == p.index,
+			Entropy:       p.entropy[i],
+			ActiveCount:   rp.ActiveCount(),
+			IdleCount:     rp.IdleCount(),
+			LastError:     p.lastErr[i].err,
+			LastErrorTime: p.lastErr[i].at,
+		}
+	}
+
+	return stats
+}
+
+// recordError records the last error of the server, the caller must hold the lock.
+func (p *Pool) recordError(index int, err error) {
+	if p.lastErr == nil {
+		p.lastErr = make(map[int]serverError)
+	}
+
+	p.lastErr[index] = serverError{err: err, at: time.Now()}
+}
+
+// triggerHealthCheck wakes up the health checker, the triggers before the check runs are merged into one.
+func (p *Pool) triggerHealthCheck() {
+	select {
+	case p.checkNow <- struct{}{}:
+	default:
+	}
+}
+
+// runHealthCheck pings all servers every HealthCheckInterval or when triggered until the Pool is closed.
+func (p *Pool) runHealthCheck() {
+	ticker := time.NewTicker(HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		case <-p.checkNow:
+		}
+
+		p.checkServers()
+	}
+}
+
+// checkServers pings all servers and updates their health,
+// the active server is switched to a healthy one if it is unhealthy or all servers were down.
+func (p *Pool) checkServers() {
+	if len(p.pools) == 0 {
+		return
+	}
+
+	errs := make([]error, len(p.pools))
+
+	var wg sync.WaitGroup
+	for i, rp := range p.pools {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			conn := rp.Get()
+			_, errs[i] = conn.Do("PING")
+			conn.Close()
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+
+	healthy := -1
+	for i, err := range errs {
+		if err != nil {
+			p.recordError(i, err)
+		} else if healthy < 0 || i == p.index {
+			healthy = i
+		}
+
+		// 当前使用的 server 由命令结果的熵值决定是否切换
+		if i != p.index {
+			p.status[i] = err == nil
+		}
+	}
+
+	if healthy >= 0 && !p.status[p.index] {
+		p.switchIndex(healthy)
+	}
+	activeErr := errs[p.index]
+
+	p.mu.Unlock()
+
+	if activeErr != nil {
+		p.FailOver()
+	}
+}
@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+// CmdInfo describes a command sent to redis, it is passed to the hooks.
+type CmdInfo struct {
+	// Ctx is the context of the command, context.Background() if the API takes no context,
+	// BeforeCmd may replace it, e.g. with a tracing span, and AfterCmd sees the replaced one.
+	Ctx context.Context
+	// Name is the command name, "PIPELINE" for Pipeline.Exec and "MULTI" for the transactions of Watch and Multi,
+	// the commands of Pipeline and Watch are in Cmds and hold the replies in AfterCmd.
+	// The scripts are run as "EVALSHA" and "EVAL" with the SHA1 digest or the source as the first argument.
+	Name string
+	Args []any
+	Cmds []*Cmd
+	// Server is the index of the server in PoolStats.Servers.
+	Server int
+	// Start is set after all BeforeCmd hooks are called, Duration and Err are set before AfterCmd.
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+
+	hooks []Hook
+}
+
+// Hook observes the commands of a Pool, e.g. for tracing, metrics and slow logs.
+// BeforeCmd is called in the order the hooks are added and AfterCmd in the reverse order.
+// A retried command of Pool.Do is observed once per attempt.
+type Hook interface {
+	BeforeCmd(info *CmdInfo)
+	AfterCmd(info *CmdInfo)
+}
+
+// HookFuncs adapts functions to a Hook, a nil function is skipped.
+type HookFuncs struct {
+	Before func(info *CmdInfo)
+	After  func(info *CmdInfo)
+}
+
+func (h HookFuncs) BeforeCmd(info *CmdInfo) {
+	if h.Before != nil {
+		h.Before(info)
+	}
+}
+
+func (h HookFuncs) AfterCmd(info *CmdInfo) {
+	if h.After != nil {
+		h.After(info)
+	}
+}
+
+// AddHook appends the hooks to the chain of the pool, it is safe to call concurrently with the commands.
+func (p *Pool) AddHook(hooks ...Hook) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var chain []Hook
+	if old := p.hooks.Load(); old != nil {
+		chain = append(chain, *old...)
+	}
+	chain = append(chain, hooks...)
+
+	p.hooks.Store(&chain)
+}
+
+// beforeCmd calls the BeforeCmd hooks, it returns nil if there is no hook.
+func (p *Pool) beforeCmd(ctx context.Context, name string, args []any, cmds []*Cmd, server int) *CmdInfo {
+	hooks := p.hooks.Load()
+	if hooks == nil || len(*hooks) == 0 {
+		return nil
+	}
+
+	info := &CmdInfo{Ctx: ctx, Name: name, Args: args, Cmds: cmds, Server: server, hooks: *hooks}
+	for _, h := range info.hooks {
+		h.BeforeCmd(info)
+	}
+	info.Start = time.Now()
+
+	return info
+}
+
+// afterCmd calls the AfterCmd hooks of info in the reverse order.
+func (p *Pool) afterCmd(info *CmdInfo, err error) {
+	if info == nil {
+		return
+	}
+
+	info.Duration = time.Since(info.Start)
+	info.Err = err
+	for i := len(info.hooks) - 1; i >= 0; i-- {
+		info.hooks[i].AfterCmd(info)
+	}
+}
+
+// SlowLogHook logs the commands slower than threshold with their server and error.
+func SlowLogHook(threshold time.Duration, logf func(format string, args ...any)) Hook {
+	return HookFuncs{
+		After: func(info *CmdInfo) {
+			if info.Duration < threshold {
+				return
+			}
+
+			if len(info.Cmds) > 0 {
+				logf("redis slow %s of %d commands on server %d took %s: %v", info.Name, len(info.Cmds), info.Server, info.Duration, info.Err)
+				return
+			}
+
+			logf("redis slow %s %v on server %d took %s: %v", info.Name, info.Args, info.Server, info.Duration, info.Err)
+		},
+	}
+}
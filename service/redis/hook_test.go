@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+func TestPool_Hooks(t *testing.T) {
+	var down0, down1 atomic.Bool
+	p := newTestPool(&down0, &down1)
+
+	var calls []string
+	var infos []*CmdInfo
+	p.AddHook(
+		HookFuncs{
+			Before: func(info *CmdInfo) {
+				calls = append(calls, "before1")
+				info.Ctx = context.WithValue(info.Ctx, ctxKey{}, "span")
+			},
+			After: func(info *CmdInfo) {
+				calls = append(calls, "after1")
+				infos = append(infos, info)
+			},
+		},
+		HookFuncs{
+			Before: func(*CmdInfo) { calls = append(calls, "before2") },
+			After: func(info *CmdInfo) {
+				calls = append(calls, "after2")
+				assert.Equal(t, "span", info.Ctx.Value(ctxKey{}))
+			},
+		},
+	)
+
+	reply, err := p.Do("PING", "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "PONG", reply)
+	assert.Equal(t, []string{"before1", "before2", "after2", "after1"}, calls)
+	assert.Len(t, infos, 1)
+	assert.Equal(t, "PING", infos[0].Name)
+	assert.Equal(t, []any{"hello"}, infos[0].Args)
+	assert.Equal(t, 0, infos[0].Server)
+	assert.NoError(t, infos[0].Err)
+	assert.False(t, infos[0].Start.IsZero())
+
+	// 网络错误时每次重试都会触发 hook
+	down0.Store(true)
+	_, err = p.Do("PING")
+	assert.Error(t, err)
+	assert.Len(t, infos, 1+FailureRetry)
+	assert.Error(t, infos[len(infos)-1].Err)
+}
+
+func TestSlowLogHook(t *testing.T) {
+	var logs []string
+	hook := SlowLogHook(100*time.Millisecond, func(format string, args ...any) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	})
+
+	hook.BeforeCmd(&CmdInfo{})
+	hook.AfterCmd(&CmdInfo{Name: "GET", Args: []any{"fast"}, Duration: time.Millisecond})
+	hook.AfterCmd(&CmdInfo{Name: "GET", Args: []any{"slow"}, Server: 1, Duration: time.Second})
+	hook.AfterCmd(&CmdInfo{Name: "PIPELINE", Cmds: make([]*Cmd, 3), Duration: time.Second})
+
+	assert.Equal(t, []string{
+		"redis slow GET [slow] on server 1 took 1s: <nil>",
+		"redis slow PIPELINE of 3 commands on server 0 took 1s: <nil>",
+	}, logs)
+}
@@ -0,0 +1,19 @@
+package redis
+
+import "github.com/gomodule/redigo/redis"
+
+// PFAdd adds the elements to the HyperLogLog, it reports whether the estimated cardinality changed.
+func (p *Pool) PFAdd(key string, elements ...any) (bool, error) {
+	return redis.Bool(p.Do("PFADD", redis.Args{}.Add(key).Add(elements...)...))
+}
+
+// PFCount returns the estimated cardinality of the union of the HyperLogLogs, the standard error is 0.81%.
+func (p *Pool) PFCount(keys ...string) (int64, error) {
+	return redis.Int64(p.Do("PFCOUNT", redis.Args{}.AddFlat(keys)...))
+}
+
+// PFMerge merges the source HyperLogLogs into dest.
+func (p *Pool) PFMerge(dest string, sources ...string) error {
+	_, err := p.Do("PFMERGE", redis.Args{}.Add(dest).AddFlat(sources)...)
+	return err
+}
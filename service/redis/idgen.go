@@ -0,0 +1,268 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrClockSkew       = errors.New("redis: local clock skews from the redis server")
+	ErrClockBackwards  = errors.New("redis: local clock moved backwards")
+	ErrNoWorkerID      = errors.New("redis: no free snowflake worker id")
+	ErrWorkerLeaseLost = errors.New("redis: snowflake worker id lease lost")
+)
+
+// SegmentIDGenerator allocates monotonic ids from a counter in blocks of step by INCRBY,
+// the ids of a block are handed out locally, the unused ids of a block are skipped after restart.
+type SegmentIDGenerator struct {
+	pool *Pool
+	key  string
+	step int64
+
+	mu sync.Mutex
+	// next and max are the bounds of the current block, next > max if it is used up
+	next int64
+	max  int64
+}
+
+func NewSegmentIDGenerator(pool *Pool, key string, step int64) (*SegmentIDGenerator, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("redis: invalid segment step: %d", step)
+	}
+
+	return &SegmentIDGenerator{pool: pool, key: key, step: step, next: 1}, nil
+}
+
+// NextID returns the next id, ids are positive and increase within the process, and are unique across processes.
+func (g *SegmentIDGenerator) NextID() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.next > g.max {
+		last, err := g.pool.IncrBy(g.key, g.step)
+		if err != nil {
+			return 0, err
+		}
+
+		g.next, g.max = last-g.step+1, last
+	}
+
+	id := g.next
+	g.next++
+
+	return id, nil
+}
+
+const (
+	snowflakeWorkerBits   = 10
+	snowflakeSequenceBits = 12
+
+	// MaxSnowflakeWorkers is the number of the worker ids a Snowflake can lease.
+	MaxSnowflakeWorkers  = 1 << snowflakeWorkerBits
+	snowflakeMaxSequence = 1<<snowflakeSequenceBits - 1
+
+	// snowflakeMaxBackwards is the longest clock moving backwards to wait for instead of failing.
+	snowflakeMaxBackwards = 5 * time.Millisecond
+)
+
+// DefaultSnowflakeEpoch is the default start time of the timestamps in the ids.
+var DefaultSnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeOptions configures a Snowflake, the zero value is valid.
+type SnowflakeOptions struct {
+	// Epoch is the start time of the timestamps, default DefaultSnowflakeEpoch, it must not change once ids are issued.
+	Epoch time.Time
+	// LeaseTTL is the ttl of the worker id lease renewed by the watchdog, default 30s.
+	LeaseTTL time.Duration
+	// MaxClockSkew is the maximal difference allowed between the local clock and the redis server, default 1s,
+	// it is checked on creation and before each renewal of the lease, NextID fails with ErrClockSkew while exceeded.
+	MaxClockSkew time.Duration
+	// OnLost is called once the lease of the worker id is lost, NextID fails with ErrWorkerLeaseLost afterward.
+	OnLost func(workerID int64, err error)
+}
+
+// Snowflake generates sortable ids of 41 bits milliseconds since the epoch, 10 bits worker id and 12 bits sequence.
+// The worker id is leased from redis with a lock renewed by its watchdog, so running generators never share one,
+// ids are only issued while the lease is valid.
+type Snowflake struct {
+	mutex    *Mutex
+	workerID int64
+	epoch    int64
+
+	mu       sync.Mutex
+	lastTime int64
+	sequence int64
+	// skewErr is the error of the last clock skew check before a renewal
+	skewErr error
+}
+
+// NewSnowflake leases a free worker id under the name and checks the local clock against the redis server, opts may be nil.
+// ctx only bounds the creation, the lease is renewed until the Snowflake is closed or the lease is lost.
+func NewSnowflake(ctx context.Context, pool *Pool, name string, opts *SnowflakeOptions) (*Snowflake, error) {
+	var o SnowflakeOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Epoch.IsZero() {
+		o.Epoch = DefaultSnowflakeEpoch
+	}
+	if o.LeaseTTL <= 0 {
+		o.LeaseTTL = 30 * time.Second
+	}
+	if o.MaxClockSkew <= 0 {
+		o.MaxClockSkew = time.Second
+	}
+
+	if err := checkClockSkew(pool, o.MaxClockSkew); err != nil {
+		return nil, err
+	}
+
+	// 从随机位置开始依次尝试租用 worker id，减少多个实例同时启动时的冲突
+	locker := NewLocker(pool)
+	start := rand.Int64N(MaxSnowflakeWorkers)
+	for i := int64(0); i < MaxSnowflakeWorkers; i++ {
+		s := &Snowflake{workerID: (start + i) % MaxSnowflakeWorkers, epoch: o.Epoch.UnixMilli(), lastTime: -1}
+		lockOpts := &LockOptions{
+			Watchdog: true,
+			beforeRenew: func() {
+				s.checkClockSkew(pool, o.MaxClockSkew)
+			},
+		}
+		if o.OnLost != nil {
+			lockOpts.OnLost = func(_ string, err error) {
+				o.OnLost(s.workerID, err)
+			}
+		}
+
+		// 租约只能通过 Close 释放，不受 ctx 取消的影响
+		m, err := locker.Obtain(ctx, name+":"+strconv.FormatInt(s.workerID, 10), o.LeaseTTL, lockOpts)
+		if errors.Is(err, ErrNotObtained) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s.mutex = m
+		return s, nil
+	}
+
+	return nil, ErrNoWorkerID
+}
+
+// checkClockSkew compares the local clock with the redis server, the round trip time is compensated.
+func checkClockSkew(pool *Pool, maxSkew time.Duration) error {
+	start := time.Now()
+	serverTime, err := pool.ServerTime()
+	if err != nil {
+		return err
+	}
+
+	rtt := time.Since(start)
+	skew := start.Add(rtt / 2).Sub(serverTime)
+	if skew > maxSkew || skew < -maxSkew {
+		return fmt.Errorf("%w: %s", ErrClockSkew, skew)
+	}
+
+	return nil
+}
+
+// checkClockSkew records the clock skew error before a renewal, the last error is kept if redis fails to reply.
+func (s *Snowflake) checkClockSkew(pool *Pool, maxSkew time.Duration) {
+	err := checkClockSkew(pool, maxSkew)
+	if err != nil && !errors.Is(err, ErrClockSkew) {
+		return
+	}
+
+	s.mu.Lock()
+	s.skewErr = err
+	s.mu.Unlock()
+}
+
+func (s *Snowflake) WorkerID() int64 {
+	return s.workerID
+}
+
+// NextID returns the next id, it waits for the next millisecond when the sequence of a millisecond is used up.
+// ErrWorkerLeaseLost is returned once the lease is lost or may have expired, e.g. the renewal is stuck,
+// since another generator may lease the worker id then.
+func (s *Snowflake) NextID() (int64, error) {
+	if err := s.mutex.Context().Err(); err != nil {
+		if cause := context.Cause(s.mutex.Context()); cause != nil && !errors.Is(cause, context.Canceled) {
+			return 0, fmt.Errorf("%w: %w", ErrWorkerLeaseLost, cause)
+		}
+
+		return 0, ErrWorkerLeaseLost
+	}
+	if remaining := s.mutex.remaining(); remaining <= 0 {
+		return 0, fmt.Errorf("%w: %w: expired %s ago", ErrWorkerLeaseLost, errLockExpiring, -remaining)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.skewErr != nil {
+		return 0, s.skewErr
+	}
+
+	now, err := s.waitTime(s.lastTime)
+	if err != nil {
+		return 0, err
+	}
+
+	if now == s.lastTime {
+		s.sequence = (s.sequence + 1) & snowflakeMaxSequence
+		if s.sequence == 0 {
+			// 当前毫秒的序号用尽，等待下一毫秒
+			if now, err = s.waitTime(s.lastTime + 1); err != nil {
+				return 0, err
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastTime = now
+
+	return snowflakeID(now, s.workerID, s.sequence), nil
+}
+
+// waitTime returns the milliseconds since the epoch not before notBefore,
+// it waits for a short backwards clock and fails for a long one.
+func (s *Snowflake) waitTime(notBefore int64) (int64, error) {
+	for {
+		now := time.Now().UnixMilli() - s.epoch
+		if now >= notBefore {
+			return now, nil
+		}
+
+		behind := time.Duration(notBefore-now) * time.Millisecond
+		if behind > snowflakeMaxBackwards+time.Millisecond {
+			return 0, fmt.Errorf("%w: %s", ErrClockBackwards, behind)
+		}
+
+		time.Sleep(behind)
+	}
+}
+
+// Close releases the worker id lease and stops its renewal, the Snowflake cannot generate ids afterward.
+func (s *Snowflake) Close(ctx context.Context) error {
+	return s.mutex.Release(ctx)
+}
+
+// ParseSnowflake splits an id into its time, worker id and sequence, epoch must be the one generating the id.
+func ParseSnowflake(id int64, epoch time.Time) (t time.Time, workerID, sequence int64) {
+	ms := id >> (snowflakeWorkerBits + snowflakeSequenceBits)
+	workerID = id >> snowflakeSequenceBits & (MaxSnowflakeWorkers - 1)
+	sequence = id & snowflakeMaxSequence
+
+	return epoch.Add(time.Duration(ms) * time.Millisecond), workerID, sequence
+}
+
+func snowflakeID(ms, workerID, sequence int64) int64 {
+	return ms<<(snowflakeWorkerBits+snowflakeSequenceBits) | workerID<<snowflakeSequenceBits | sequence
+}
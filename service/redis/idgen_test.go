@@ -0,0 +1,144 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSnowflake(workerID int64) *Snowflake {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &Snowflake{
+		mutex:    &Mutex{ctx: ctx, cancel: cancel, validUntil: time.Now().Add(time.Hour)},
+		workerID: workerID,
+		epoch:    DefaultSnowflakeEpoch.UnixMilli(),
+		lastTime: -1,
+	}
+}
+
+func TestSnowflake_NextID(t *testing.T) {
+	s := newTestSnowflake(MaxSnowflakeWorkers - 1)
+
+	var last int64
+	for i := 0; i < 3*snowflakeMaxSequence; i++ {
+		id, err := s.NextID()
+		assert.NoError(t, err)
+		assert.Greater(t, id, last)
+		last = id
+	}
+
+	ts, workerID, _ := ParseSnowflake(last, DefaultSnowflakeEpoch)
+	assert.Equal(t, int64(MaxSnowflakeWorkers-1), workerID)
+	assert.WithinDuration(t, time.Now(), ts, time.Second)
+
+	s.mutex.cancel(ErrLockNotHeld)
+	_, err := s.NextID()
+	assert.ErrorIs(t, err, ErrWorkerLeaseLost)
+	assert.ErrorIs(t, err, ErrLockNotHeld)
+}
+
+func TestSnowflake_LeaseExpiring(t *testing.T) {
+	s := newTestSnowflake(1)
+	_, err := s.NextID()
+	assert.NoError(t, err)
+
+	// 续期阻塞时，租约有效期结束前停止发号
+	s.mutex.validUntil = time.Now().Add(-time.Millisecond)
+	_, err = s.NextID()
+	assert.ErrorIs(t, err, ErrWorkerLeaseLost)
+	assert.ErrorIs(t, err, errLockExpiring)
+}
+
+func TestNewSnowflake(t *testing.T) {
+	p, r := newMiniredisPool(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s1, err := NewSnowflake(ctx, p, "id", &SnowflakeOptions{LeaseTTL: 90 * time.Millisecond})
+	assert.NoError(t, err)
+	s2, err := NewSnowflake(ctx, p, "id", nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, s1.WorkerID(), s2.WorkerID())
+
+	// 创建后取消 ctx 不影响租约续期
+	cancel()
+	time.Sleep(200 * time.Millisecond)
+	_, err = s1.NextID()
+	assert.NoError(t, err)
+	assert.NoError(t, s1.mutex.Context().Err())
+	assert.Positive(t, s1.mutex.remaining())
+
+	key := "id:" + strconv.FormatInt(s1.WorkerID(), 10)
+	assert.True(t, r.Exists(key))
+	assert.NoError(t, s1.Close(context.Background()))
+	assert.False(t, r.Exists(key))
+	_, err = s1.NextID()
+	assert.ErrorIs(t, err, ErrWorkerLeaseLost)
+
+	assert.NoError(t, s2.Close(context.Background()))
+}
+
+func TestNewSnowflake_ClockSkew(t *testing.T) {
+	p, r := newMiniredisPool(t)
+
+	r.SetTime(time.Now().Add(time.Hour))
+	_, err := NewSnowflake(context.Background(), p, "id", nil)
+	assert.ErrorIs(t, err, ErrClockSkew)
+
+	r.SetTime(time.Now())
+	s, err := NewSnowflake(context.Background(), p, "id", &SnowflakeOptions{LeaseTTL: 60 * time.Millisecond})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close(context.Background()) })
+
+	// 续期前重新检查时钟，偏差过大时停止发号，恢复后继续
+	r.SetTime(time.Now().Add(time.Hour))
+	assert.Eventually(t, func() bool {
+		_, err := s.NextID()
+		return errors.Is(err, ErrClockSkew)
+	}, time.Second, 10*time.Millisecond)
+
+	r.SetTime(time.Now())
+	assert.Eventually(t, func() bool {
+		_, err := s.NextID()
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSnowflake_ClockBackwards(t *testing.T) {
+	s := newTestSnowflake(1)
+	s.lastTime = time.Now().Add(time.Minute).UnixMilli() - s.epoch
+
+	_, err := s.NextID()
+	assert.True(t, errors.Is(err, ErrClockBackwards))
+
+	s.lastTime = time.Now().Add(2*time.Millisecond).UnixMilli() - s.epoch
+	id, err := s.NextID()
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, id>>(snowflakeWorkerBits+snowflakeSequenceBits), s.lastTime)
+}
+
+func TestParseSnowflake(t *testing.T) {
+	at := DefaultSnowflakeEpoch.Add(123456 * time.Millisecond)
+	ts, workerID, sequence := ParseSnowflake(snowflakeID(123456, 42, 7), DefaultSnowflakeEpoch)
+	assert.Equal(t, at, ts)
+	assert.Equal(t, int64(42), workerID)
+	assert.Equal(t, int64(7), sequence)
+}
+
+func TestNewSegmentIDGenerator(t *testing.T) {
+	_, err := NewSegmentIDGenerator(nil, "id", 0)
+	assert.Error(t, err)
+
+	g, err := NewSegmentIDGenerator(nil, "id", 100)
+	assert.NoError(t, err)
+	g.next, g.max = 5, 6
+
+	for _, want := range []int64{5, 6} {
+		id, err := g.NextID()
+		assert.NoError(t, err)
+		assert.Equal(t, want, id)
+	}
+}
@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrQueueFull is returned when enqueuing to a queue whose length reaches the hard limit.
+var ErrQueueFull = errors.New("redis: queue is full")
+
+// QueueLimit configures the length limits of a queue, a zero limit means unlimited.
+type QueueLimit struct {
+	// SoftLimit reports the queue length to OnLimit when it is reached, the job is still enqueued.
+	SoftLimit int64
+	// HardLimit rejects new jobs with ErrQueueFull when it is reached, merging data of a queued key is still allowed.
+	HardLimit int64
+	// BlockTimeout waits at most the timeout for the queue to drop below HardLimit before rejecting.
+	BlockTimeout time.Duration
+	// OnLimit is called when the queue length reaches a limit, hard reports whether it is the hard limit.
+	OnLimit func(queueName string, length int64, hard bool)
+}
+
+// DefaultQueueLimit is used by the queues without SetQueueLimit, it only reports the queues longer than 100.
+var DefaultQueueLimit = QueueLimit{SoftLimit: 100}
+
+var queueLimits sync.Map
+
+// SetQueueLimit configures the length limits of the queue used by EnQueue and DeQueue.
+func SetQueueLimit(queueName string, limit QueueLimit) {
+	queueLimits.Store(queueName, limit)
+}
+
+func getQueueLimit(queueName string) QueueLimit {
+	if limit, ok := queueLimits.Load(queueName); ok {
+		return limit.(QueueLimit)
+	}
+
+	return DefaultQueueLimit
+}
+
+// reportQueueLen calls OnLimit if the length reaches a limit, it reports whether the hard limit is reached.
+func (l QueueLimit) reportQueueLen(queueName string, length int64) bool {
+	hard := l.HardLimit > 0 && length >= l.HardLimit
+	if (hard || (l.SoftLimit > 0 && length >= l.SoftLimit)) && l.OnLimit != nil {
+		l.OnLimit(queueName, length, hard)
+	}
+
+	return hard
+}
+
+// enQueueLimited enqueues the key by ScriptEnQueue which checks the hard limit atomically,
+// it waits for the queue to drop below the hard limit within BlockTimeout, ErrQueueFull is returned on timeout.
+// OnLimit is called at most once for an enqueue.
+func enQueueLimited(pool *Pool, queueName, key string, data []byte) error {
+	limit := getQueueLimit(queueName)
+	deadline := time.Now().Add(limit.BlockTimeout)
+	backoff := ExponentialBackoff(10*time.Millisecond, time.Second)
+	for attempt := 1; ; attempt++ {
+		values, err := redis.Int64s(pool.EvalScript(scriptEnQueue, queueName, queueName+hTable, key, data, limit.HardLimit))
+		if err != nil {
+			return err
+		}
+		if len(values) != 2 {
+			return WrongAnswer
+		}
+
+		added, length := values[0], values[1]
+		if added >= 0 {
+			if attempt == 1 {
+				limit.reportQueueLen(queueName, length)
+			}
+
+			return nil
+		}
+
+		// 队列已满，阻塞等待期间只通知一次
+		if attempt == 1 && limit.OnLimit != nil {
+			limit.OnLimit(queueName, length, true)
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return ErrQueueFull
+		}
+
+		time.Sleep(min(backoff.Backoff(attempt), wait))
+	}
+}
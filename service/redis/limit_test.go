@@ -0,0 +1,151 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueLimit_reportQueueLen(t *testing.T) {
+	testCases := []struct {
+		name   string
+		length int64
+
+		wantHard   bool
+		wantReport []bool
+	}{
+		{
+			name:   "below soft limit",
+			length: 9,
+		},
+		{
+			name:       "soft limit",
+			length:     10,
+			wantReport: []bool{false},
+		},
+		{
+			name:       "hard limit",
+			length:     100,
+			wantHard:   true,
+			wantReport: []bool{true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var report []bool
+			limit := QueueLimit{
+				SoftLimit: 10,
+				HardLimit: 100,
+				OnLimit: func(queueName string, length int64, hard bool) {
+					assert.Equal(t, "queue", queueName)
+					assert.Equal(t, tc.length, length)
+					report = append(report, hard)
+				},
+			}
+
+			assert.Equal(t, tc.wantHard, limit.reportQueueLen("queue", tc.length))
+			assert.Equal(t, tc.wantReport, report)
+		})
+	}
+}
+
+func TestSetQueueLimit(t *testing.T) {
+	assert.Equal(t, DefaultQueueLimit, getQueueLimit("limited"))
+
+	SetQueueLimit("limited", QueueLimit{HardLimit: 1})
+	t.Cleanup(func() { queueLimits.Delete("limited") })
+	assert.Equal(t, int64(1), getQueueLimit("limited").HardLimit)
+}
+
+func TestEnQueue_HardLimit(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	var reports []int64
+	SetQueueLimit("full", QueueLimit{
+		HardLimit:    2,
+		BlockTimeout: 100 * time.Millisecond,
+		OnLimit: func(queueName string, length int64, hard bool) {
+			if hard {
+				reports = append(reports, length)
+			}
+		},
+	})
+	t.Cleanup(func() { queueLimits.Delete("full") })
+
+	assert.NoError(t, EnQueue(p, "full", "a", []byte("1")))
+	assert.NoError(t, EnQueue(p, "full", "b", []byte("2")))
+	assert.Equal(t, []int64{2}, reports)
+
+	// 阻塞等待期间多次重试，只通知一次
+	reports = nil
+	start := time.Now()
+	assert.ErrorIs(t, EnQueue(p, "full", "c", []byte("3")), ErrQueueFull)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, []int64{2}, reports)
+	assert.Equal(t, "", s.HGet("full"+hTable, "c"))
+
+	// 已在队列中的 key 可以合并数据
+	assert.NoError(t, EnQueue(p, "full", "a", []byte("4")))
+	assert.Equal(t, "4", s.HGet("full"+hTable, "a"))
+
+	// 队列被消费后阻塞的入队成功
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		DeQueue(p, "full")
+	}()
+	assert.NoError(t, EnQueue(p, "full", "c", []byte("3")))
+	keys, err := s.List("full")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, keys)
+}
+
+func TestEnQueue_HardLimitConcurrent(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	SetQueueLimit("full", QueueLimit{HardLimit: 5})
+	t.Cleanup(func() { queueLimits.Delete("full") })
+
+	var wg sync.WaitGroup
+	var full atomic.Int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errors.Is(EnQueue(p, "full", strconv.Itoa(i), nil), ErrQueueFull) {
+				full.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	keys, err := s.List("full")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 5)
+	assert.Equal(t, int64(15), full.Load())
+}
+
+func TestDeQueue(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	key, data := DeQueue(p, "queue")
+	assert.Equal(t, "", key)
+	assert.Nil(t, data)
+
+	assert.NoError(t, EnQueue(p, "queue", "a", []byte("1")))
+	assert.NoError(t, EnQueue(p, "queue", "a", []byte("2")))
+	assert.NoError(t, EnQueue(p, "queue", "b", nil))
+
+	key, data = DeQueue(p, "queue")
+	assert.Equal(t, "a", key)
+	assert.Equal(t, []byte("2"), data)
+	key, data = DeQueue(p, "queue")
+	assert.Equal(t, "b", key)
+	assert.Empty(t, data)
+	assert.False(t, s.Exists("queue"))
+	assert.False(t, s.Exists("queue"+hTable))
+}
@@ -1,9 +1,6 @@
 package redis
 
-import (
-	"fmt"
-	"github.com/gomodule/redigo/redis"
-)
+import "github.com/gomodule/redigo/redis"
 
 func (p *Pool) LPush(args ...any) error {
 	_, err := p.Do("LPUSH", args...)
@@ -23,6 +20,11 @@ func (p *Pool) LRange(key string, start, end int) (any, error) {
 	return result, nil
 }
 
+// LRangeStrings returns the elements between start and end as strings.
+func (p *Pool) LRangeStrings(key string, start, end int) ([]string, error) {
+	return redis.Strings(p.Do("LRANGE", key, start, end))
+}
+
 func (p *Pool) RPush(args ...any) error {
 	_, err := p.Do("RPUSH", args...)
 	if err != nil {
@@ -41,18 +43,13 @@ func (p *Pool) LPop(key string) (any, error) {
 	return result, nil
 }
 
-func (p *Pool) LLen(key string) (int64, error) {
-	result, err := p.Do("LLEN", key)
-	if err != nil {
-		return 0, err
-	}
-
-	length, ok := result.(int64)
-	if !ok {
-		err = fmt.Errorf("%s value is not a int64", key)
-	}
+// LPopString pops the first element, ErrNil is returned if the list is empty.
+func (p *Pool) LPopString(key string) (string, error) {
+	return redis.String(p.Do("LPOP", key))
+}
 
-	return length, err
+func (p *Pool) LLen(key string) (int64, error) {
+	return redis.Int64(p.Do("LLEN", key))
 }
 
 func (p *Pool) RPop(key string) (any, error) {
@@ -63,3 +60,8 @@ func (p *Pool) RPop(key string) (any, error) {
 
 	return result, nil
 }
+
+// RPopString pops the last element, ErrNil is returned if the list is empty.
+func (p *Pool) RPopString(key string) (string, error) {
+	return redis.String(p.Do("RPOP", key))
+}
@@ -0,0 +1,623 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dapings/kit/uuidx"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// ScriptUnlock deletes the lock only if it is still held by the token.
+	ScriptUnlock = `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("DEL", KEYS[1])
+		end
+		return 0
+	`
+	// ScriptRefreshLock resets the ttl of the lock only if it is still held by the token.
+	ScriptRefreshLock = `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		end
+		return 0
+	`
+	// ScriptUnlockNotify is the same as ScriptUnlock and publishes a message to the channel ARGV[2] after unlocked.
+	ScriptUnlockNotify = `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			redis.call("DEL", KEYS[1])
+			redis.call("PUBLISH", ARGV[2], KEYS[1])
+			return 1
+		end
+		return 0
+	`
+	// ScriptLockPTTL returns the remaining ttl in milliseconds of the lock held by the token, -1 if not held.
+	ScriptLockPTTL = `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("PTTL", KEYS[1])
+		end
+		return -1
+	`
+)
+
+var (
+	scriptUnlock       = RegisterScript("lock.unlock", 1, ScriptUnlock)
+	scriptRefreshLock  = RegisterScript("lock.refresh", 1, ScriptRefreshLock)
+	scriptUnlockNotify = RegisterScript("lock.unlock_notify", 1, ScriptUnlockNotify)
+	scriptLockPTTL     = RegisterScript("lock.pttl", 1, ScriptLockPTTL)
+)
+
+var (
+	// ErrNotObtained is returned when the lock is held by others.
+	ErrNotObtained = errors.New("redis: lock not obtained")
+	// ErrLockNotHeld is returned when releasing or refreshing a lock which is expired or held by others.
+	ErrLockNotHeld = errors.New("redis: lock not held")
+	// errLockExpiring is the cause of a lock given up by the watchdog because it may expire before the next renewal.
+	errLockExpiring = errors.New("redis: lock may expire before renewal")
+
+	// RedlockDriftFactor is the clock drift of the redis instances in proportion to the lock ttl.
+	RedlockDriftFactor = 0.01
+)
+
+// lockReleasedSuffix is the suffix of the channel which a lock release is published to.
+const lockReleasedSuffix = "_released"
+
+// lockScripts are the scripts to operate a kind of lock, KEYS[1] is the lock key and ARGV[1] is the owner token.
+type lockScripts struct {
+	// obtain locks with ttl ARGV[2] and returns 1 if obtained, SET NX PX is used if nil
+	obtain *Script
+	unlock *Script
+	// unlockNotify unlocks and publishes to the channel ARGV[2]
+	unlockNotify *Script
+	// refresh resets the ttl to ARGV[2] and returns 1 if the lock is held
+	refresh *Script
+	pttl    *Script
+}
+
+var mutexScripts = &lockScripts{
+	unlock:       scriptUnlock,
+	unlockNotify: scriptUnlockNotify,
+	refresh:      scriptRefreshLock,
+	pttl:         scriptLockPTTL,
+}
+
+// LockOptions configures the obtained lock, the zero value obtains the lock once without automatic renewal.
+type LockOptions struct {
+	// Token identifies the owner of the lock, a random token is used if empty.
+	// A reentrant lock can be obtained again with the token of its holder.
+	Token string
+
+	// Retry decides the backoff between attempts when the lock is held by others, nil means no retry.
+	// Retrying stops when the ctx passed to Obtain is done.
+	Retry RetryStrategy
+	// WaitRelease subscribes to the release of the lock while waiting to retry, so waiters are woken up
+	// as soon as the holder releases it instead of sleeping the whole backoff.
+	// The holder publishes the release only if it was obtained with WaitRelease too.
+	WaitRelease bool
+
+	// Watchdog renews the lock every ttl/3 until it is released or lost,
+	// the ctx passed to Obtain only bounds the acquisition and does not stop the renewal.
+	Watchdog bool
+	// OnLost is called once the watchdog fails to renew the lock before it may expire,
+	// Mutex.Context is cancelled with the same error at that time.
+	OnLost func(key string, err error)
+
+	// beforeRenew is called by the watchdog before each renewal, e.g. to check the clock of a Snowflake.
+	beforeRenew func()
+}
+
+// Locker obtains distributed locks on one or more independent redis instances.
+type Locker struct {
+	nodes  []lockNode
+	quorum int
+}
+
+// lockNode is a redis instance which the lock is written to.
+type lockNode interface {
+	Do(cmdStr string, args ...any) (any, error)
+	// conn dials a dedicated connection out of the pool for subscribing,
+	// closing it does not send UNSUBSCRIBE and read the replies like a pooled connection does.
+	conn() (redis.Conn, error)
+}
+
+// instance is a single redis instance without retry and failover.
+type instance struct {
+	pool *redis.Pool
+}
+
+func (n instance) Do(cmdStr string, args ...any) (any, error) {
+	conn := n.pool.Get()
+	defer conn.Close()
+
+	return conn.Do(cmdStr, args...)
+}
+
+func (n instance) conn() (redis.Conn, error) {
+	return n.pool.Dial()
+}
+
+// NewLocker returns a Locker writing the locks to the active redis instance of the pool.
+func NewLocker(pool *Pool) *Locker {
+	return &Locker{nodes: []lockNode{pool}, quorum: 1}
+}
+
+// NewRedlock returns a Locker implementing the Redlock algorithm on the pools,
+// each pool must be an independent redis master, a lock is obtained when the majority of them are locked.
+func NewRedlock(pools ...*Pool) *Locker {
+	nodes := make([]lockNode, 0, len(pools))
+	for _, pool := range pools {
+		nodes = append(nodes, pool)
+	}
+
+	return &Locker{nodes: nodes, quorum: len(nodes)/2 + 1}
+}
+
+// NewRedlockOnServers returns a Locker implementing the Redlock algorithm on every server of the pool,
+// the servers are used as independent redis masters instead of failover backups.
+func NewRedlockOnServers(pool *Pool) *Locker {
+	nodes := make([]lockNode, 0, len(pool.pools))
+	for _, rp := range pool.pools {
+		nodes = append(nodes, instance{pool: rp})
+	}
+
+	return &Locker{nodes: nodes, quorum: len(nodes)/2 + 1}
+}
+
+// Obtain tries to obtain the lock of key with opt.Token or a random token, the lock expires after ttl if not released.
+// The attempt is retried with opt.Retry while the lock is held by others,
+// ErrNotObtained is returned when retrying stops, opt may be nil.
+func (l *Locker) Obtain(ctx context.Context, key string, ttl time.Duration, opt *LockOptions) (*Mutex, error) {
+	return l.obtainLock(ctx, key, ttl, opt, mutexScripts)
+}
+
+func (l *Locker) obtainLock(ctx context.Context, key string, ttl time.Duration, opt *LockOptions, scripts *lockScripts) (*Mutex, error) {
+	if key == "" {
+		return nil, errors.New("redis: lock key is empty")
+	}
+	if ttl < time.Millisecond {
+		return nil, errors.New("redis: lock ttl must be at least 1ms")
+	}
+	if len(l.nodes) == 0 {
+		return nil, ServerEmptyErr
+	}
+	if opt == nil {
+		opt = &LockOptions{}
+	}
+	retry := opt.Retry
+	if retry == nil {
+		retry = NoRetry()
+	}
+	token := opt.Token
+	if token == "" {
+		token = uuidx.UUID4()
+	}
+
+	var waiter *releaseWaiter
+	defer func() {
+		waiter.close()
+	}()
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNotObtained, err)
+		}
+
+		validUntil, err := l.obtain(scripts, key, token, ttl)
+		if err == nil {
+			return newMutex(ctx, l, scripts, key, token, ttl, validUntil, opt), nil
+		}
+		if !errors.Is(err, ErrNotObtained) {
+			return nil, err
+		}
+
+		backoff := retry.Backoff(attempt)
+		if backoff < 0 {
+			return nil, ErrNotObtained
+		}
+
+		// 第一次加锁失败后才订阅释放通知
+		if opt.WaitRelease && waiter == nil {
+			waiter = l.subscribeRelease(key)
+		}
+		if err = waiter.wait(ctx, backoff); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNotObtained, err)
+		}
+	}
+}
+
+// obtain sets the lock on all nodes once, it returns the time until which the obtained lock is valid.
+func (l *Locker) obtain(scripts *lockScripts, key, token string, ttl time.Duration) (time.Time, error) {
+	var (
+		mu     sync.Mutex
+		locked []lockNode
+	)
+	start := time.Now()
+	n, err := l.eachNode(func(node lockNode) (bool, error) {
+		var ok bool
+		if scripts.obtain == nil {
+			result, err := node.Do("SET", key, token, "NX", "PX", ttl.Milliseconds())
+			if ok = result != nil; !ok {
+				return false, err
+			}
+		} else {
+			n, err := redis.Int64(evalScript(node, scripts.obtain, key, token, ttl.Milliseconds()))
+			if ok = n == 1; !ok {
+				return false, err
+			}
+		}
+
+		mu.Lock()
+		locked = append(locked, node)
+		mu.Unlock()
+
+		return true, nil
+	})
+
+	// 扣除加锁耗时及时钟漂移后，锁的剩余有效时间必须为正
+	validUntil := lockValidUntil(start, ttl)
+	if n >= l.quorum && (len(l.nodes) == 1 || time.Until(validUntil) > 0) {
+		return validUntil, nil
+	}
+
+	// 未达到多数派时只释放本次加锁成功的节点，
+	// 失败或拒绝的节点上并没有本次加的锁，重入锁在这些节点上若被释放会减掉之前的持有次数
+	if len(locked) > 0 {
+		rollback := &Locker{nodes: locked}
+		_, _ = rollback.eachNode(func(node lockNode) (bool, error) {
+			return unlockNode(node, scripts, key, token, "")
+		})
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Time{}, ErrNotObtained
+}
+
+// lockValidUntil returns the time until which a lock with ttl set since start is valid, the clock drift is deducted.
+func lockValidUntil(start time.Time, ttl time.Duration) time.Time {
+	drift := time.Duration(float64(ttl)*RedlockDriftFactor) + 2*time.Millisecond
+	return start.Add(ttl - drift)
+}
+
+// releaseWaiter receives the release notifications of a lock.
+type releaseWaiter struct {
+	conn     redis.PubSubConn
+	released chan struct{}
+	// done is closed when the receiving goroutine exits
+	done chan struct{}
+}
+
+// subscribeRelease subscribes to the release of key on the first node,
+// it returns nil if subscribing fails and waiting falls back to sleeping.
+func (l *Locker) subscribeRelease(key string) *releaseWaiter {
+	conn, err := l.nodes[0].conn()
+	if err != nil {
+		return nil
+	}
+
+	w := &releaseWaiter{
+		conn:     redis.PubSubConn{Conn: conn},
+		released: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if err = w.conn.Subscribe(key + lockReleasedSuffix); err != nil {
+		w.conn.Close()
+		return nil
+	}
+
+	go func() {
+		defer close(w.done)
+
+		for {
+			// 订阅连接不设置读超时，关闭连接后退出
+			switch w.conn.ReceiveWithTimeout(0).(type) {
+			case redis.Message:
+				select {
+				case w.released <- struct{}{}:
+				default:
+				}
+			case error:
+				return
+			}
+		}
+	}()
+
+	return w
+}
+
+// wait waits for the backoff, a release notification or the ctx done.
+func (w *releaseWaiter) wait(ctx context.Context, backoff time.Duration) error {
+	var released chan struct{}
+	if w != nil {
+		released = w.released
+	}
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	case <-released:
+	}
+
+	return nil
+}
+
+// close closes the connection and waits for the receiving goroutine to exit.
+func (w *releaseWaiter) close() {
+	if w != nil {
+		w.conn.Close()
+		<-w.done
+	}
+}
+
+// eachNode calls fn on every node concurrently, it returns the number of nodes fn succeeded on,
+// and the joined errors if no node failed without error.
+func (l *Locker) eachNode(fn func(node lockNode) (bool, error)) (int, error) {
+	if len(l.nodes) == 1 {
+		ok, err := fn(l.nodes[0])
+		if ok {
+			return 1, nil
+		}
+
+		return 0, err
+	}
+
+	type result struct {
+		ok  bool
+		err error
+	}
+	results := make(chan result, len(l.nodes))
+	for _, node := range l.nodes {
+		go func(node lockNode) {
+			ok, err := fn(node)
+			results <- result{ok: ok, err: err}
+		}(node)
+	}
+
+	var (
+		n        int
+		rejected bool
+		errs     []error
+	)
+	for range l.nodes {
+		r := <-results
+		switch {
+		case r.ok:
+			n++
+		case r.err != nil:
+			errs = append(errs, r.err)
+		default:
+			rejected = true
+		}
+	}
+
+	if rejected {
+		return n, nil
+	}
+
+	return n, errors.Join(errs...)
+}
+
+// unlockNode deletes the lock held by token, the release is published to channel if it is not empty.
+func unlockNode(node lockNode, scripts *lockScripts, key, token, channel string) (bool, error) {
+	var n int64
+	var err error
+	if channel == "" {
+		n, err = redis.Int64(evalScript(node, scripts.unlock, key, token))
+	} else {
+		n, err = redis.Int64(evalScript(node, scripts.unlockNotify, key, token, channel))
+	}
+
+	return n == 1, err
+}
+
+// Mutex is an obtained lock, it is identified by the key and the random token of the owner.
+// A Mutex obtained by Redlock is held as long as the majority of the nodes are locked.
+type Mutex struct {
+	locker  *Locker
+	scripts *lockScripts
+	key     string
+	token   string
+	notify  bool
+
+	mu  sync.Mutex
+	ttl time.Duration
+	// validUntil is measured from the start of the last successful obtain or refresh
+	validUntil time.Time
+
+	// ctx is cancelled when the lock is released or lost
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+func newMutex(ctx context.Context, l *Locker, scripts *lockScripts, key, token string, ttl time.Duration, validUntil time.Time, opt *LockOptions) *Mutex {
+	m := &Mutex{locker: l, scripts: scripts, key: key, token: token, ttl: ttl, validUntil: validUntil, notify: opt.WaitRelease}
+	// 锁的生命周期与获取锁时的 ctx 无关，只保留其中的值
+	m.ctx, m.cancel = context.WithCancelCause(context.WithoutCancel(ctx))
+
+	if opt.Watchdog {
+		go m.watchdog(opt.OnLost, opt.beforeRenew)
+	}
+
+	return m
+}
+
+// watchdog renews the lock every ttl/3 until the lock is released.
+// 续期失败时继续重试；锁已被他人持有，或剩余有效时间不足一个续期间隔、下次续期前锁可能已过期时，
+// 即认为锁已丢失，保证持有者在锁真正过期之前得到通知。
+func (m *Mutex) watchdog(onLost func(key string, err error), beforeRenew func()) {
+	timer := time.NewTimer(m.renewInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if beforeRenew != nil {
+			beforeRenew()
+		}
+
+		err := m.Refresh(m.ctx, m.currentTTL())
+		if m.ctx.Err() != nil {
+			// released during renewal
+			return
+		}
+
+		if remaining := m.remaining(); errors.Is(err, ErrLockNotHeld) || remaining < m.renewInterval() {
+			if err == nil {
+				err = fmt.Errorf("%w: %s left before the next renewal", errLockExpiring, remaining)
+			}
+
+			m.cancel(err)
+			if onLost != nil {
+				onLost(m.key, err)
+			}
+
+			return
+		}
+
+		timer.Reset(m.renewInterval())
+	}
+}
+
+// remaining returns the validity of the lock left since the last successful obtain or refresh.
+func (m *Mutex) remaining() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return time.Until(m.validUntil)
+}
+
+func (m *Mutex) currentTTL() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.ttl
+}
+
+func (m *Mutex) renewInterval() time.Duration {
+	if interval := m.currentTTL() / 3; interval > time.Millisecond {
+		return interval
+	}
+
+	return time.Millisecond
+}
+
+// Context returns a context with the values of the one passed to Obtain but not its cancellation,
+// it is cancelled when the lock is released or the watchdog fails to renew it,
+// context.Cause reports the renewal error in the latter case.
+func (m *Mutex) Context() context.Context {
+	return m.ctx
+}
+
+func (m *Mutex) Key() string {
+	return m.key
+}
+
+func (m *Mutex) Token() string {
+	return m.token
+}
+
+// TTL returns the remaining time to live of the lock, ErrLockNotHeld is returned if the lock is lost.
+// The minimal ttl of the locked nodes is returned for Redlock.
+func (m *Mutex) TTL(ctx context.Context) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var (
+		mu  sync.Mutex
+		ttl = time.Duration(-1)
+	)
+	l := m.locker
+	n, err := l.eachNode(func(node lockNode) (bool, error) {
+		ms, err := redis.Int64(evalScript(node, m.scripts.pttl, m.key, m.token))
+		if err != nil || ms < 0 {
+			return false, err
+		}
+
+		mu.Lock()
+		if d := time.Duration(ms) * time.Millisecond; ttl < 0 || d < ttl {
+			ttl = d
+		}
+		mu.Unlock()
+
+		return true, nil
+	})
+	if n >= l.quorum {
+		return ttl, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, ErrLockNotHeld
+}
+
+// Refresh extends the lock with a new ttl, ErrLockNotHeld is returned if the lock is lost.
+func (m *Mutex) Refresh(ctx context.Context, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	l := m.locker
+	n, err := l.eachNode(func(node lockNode) (bool, error) {
+		n, err := redis.Int64(evalScript(node, m.scripts.refresh, m.key, m.token, ttl.Milliseconds()))
+		return n == 1, err
+	})
+	if n < l.quorum {
+		if err != nil {
+			return err
+		}
+
+		return ErrLockNotHeld
+	}
+
+	m.mu.Lock()
+	m.ttl = ttl
+	m.validUntil = lockValidUntil(start, ttl)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Release releases the lock on all nodes if it is still held by the token and stops the watchdog,
+// ErrLockNotHeld is returned if the lock is lost.
+func (m *Mutex) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.cancel(nil)
+
+	l := m.locker
+	var channel string
+	if m.notify {
+		channel = m.key + lockReleasedSuffix
+	}
+	n, err := l.eachNode(func(node lockNode) (bool, error) {
+		return unlockNode(node, m.scripts, m.key, m.token, channel)
+	})
+	if n < l.quorum {
+		if err != nil {
+			return err
+		}
+
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
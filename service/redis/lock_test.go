@@ -0,0 +1,333 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNode is a lock node which accepts or rejects all locks.
+type fakeNode struct {
+	mu     sync.Mutex
+	accept bool
+	err    error
+	// refreshErr fails the refreshes, refreshReject rejects them as the lock is held by others
+	refreshErr    error
+	refreshReject bool
+
+	sets      int
+	unlocked  int
+	refreshed int
+}
+
+func (n *fakeNode) Do(cmdStr string, args ...any) (any, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.err != nil {
+		return nil, n.err
+	}
+
+	switch {
+	case cmdStr == "SET":
+		n.sets++
+		if n.accept {
+			return "OK", nil
+		}
+		return nil, nil
+	case cmdStr == "EVALSHA" && args[0] == scriptRefreshLock.Hash():
+		n.refreshed++
+		if n.refreshErr != nil {
+			return nil, n.refreshErr
+		}
+		if n.refreshReject {
+			return int64(0), nil
+		}
+		return int64(1), nil
+	case cmdStr == "EVALSHA":
+		n.unlocked++
+		if n.accept {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+
+	return nil, errors.New("unknown command")
+}
+
+func (n *fakeNode) set(fn func(n *fakeNode)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	fn(n)
+}
+
+func (n *fakeNode) refreshes() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.refreshed
+}
+
+func (n *fakeNode) conn() (redis.Conn, error) {
+	return nil, errors.New("subscribe is not supported")
+}
+
+func TestLocker_Obtain(t *testing.T) {
+	netErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	testCases := []struct {
+		name  string
+		nodes []*fakeNode
+
+		wantErr      error
+		wantUnlocked []int
+	}{
+		{
+			name:         "single node",
+			nodes:        []*fakeNode{{accept: true}},
+			wantUnlocked: []int{0},
+		},
+		{
+			name:         "single node held by others",
+			nodes:        []*fakeNode{{}},
+			wantErr:      ErrNotObtained,
+			wantUnlocked: []int{0},
+		},
+		{
+			name:         "single node error",
+			nodes:        []*fakeNode{{err: netErr}},
+			wantErr:      netErr,
+			wantUnlocked: []int{0},
+		},
+		{
+			name:         "quorum",
+			nodes:        []*fakeNode{{accept: true}, {accept: true}, {}},
+			wantUnlocked: []int{0, 0, 0},
+		},
+		{
+			name:         "quorum with error node",
+			nodes:        []*fakeNode{{accept: true}, {err: netErr}, {accept: true}},
+			wantUnlocked: []int{0, 0, 0},
+		},
+		{
+			name:         "minority",
+			nodes:        []*fakeNode{{accept: true}, {}, {}},
+			wantErr:      ErrNotObtained,
+			wantUnlocked: []int{1, 0, 0},
+		},
+		{
+			name:         "minority with error nodes",
+			nodes:        []*fakeNode{{accept: true}, {err: netErr}, {err: netErr}},
+			wantErr:      netErr,
+			wantUnlocked: []int{1, 0, 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodes := make([]lockNode, 0, len(tc.nodes))
+			for _, node := range tc.nodes {
+				nodes = append(nodes, node)
+			}
+			l := &Locker{nodes: nodes, quorum: len(nodes)/2 + 1}
+
+			m, err := l.Obtain(context.Background(), "key", time.Second, nil)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.Equal(t, "key", m.Key())
+				assert.NotEmpty(t, m.Token())
+			}
+
+			unlocked := make([]int, 0, len(tc.nodes))
+			for _, node := range tc.nodes {
+				unlocked = append(unlocked, node.unlocked)
+			}
+			assert.Equal(t, tc.wantUnlocked, unlocked)
+		})
+	}
+}
+
+func TestLocker_ObtainRetry(t *testing.T) {
+	node := &fakeNode{}
+	l := &Locker{nodes: []lockNode{node}, quorum: 1}
+
+	opt := &LockOptions{Retry: LimitRetry(ConstantBackoff(time.Millisecond), 2)}
+	_, err := l.Obtain(context.Background(), "key", time.Second, opt)
+	assert.ErrorIs(t, err, ErrNotObtained)
+	assert.Equal(t, 3, node.sets)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	opt = &LockOptions{Retry: ConstantBackoff(time.Hour), WaitRelease: true}
+	_, err = l.Obtain(ctx, "key", time.Second, opt)
+	assert.ErrorIs(t, err, ErrNotObtained)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLocker_ObtainWaitRelease(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+	l := NewLocker(p)
+
+	m, err := l.Obtain(context.Background(), "key", time.Minute, &LockOptions{WaitRelease: true})
+	assert.NoError(t, err)
+
+	// 等待者订阅释放通知，持有者释放后立即被唤醒，而不是等待整个退避时间
+	obtained := make(chan *Mutex, 1)
+	go func() {
+		m, err := l.Obtain(context.Background(), "key", time.Minute, &LockOptions{Retry: ConstantBackoff(5 * time.Second), WaitRelease: true})
+		assert.NoError(t, err)
+		obtained <- m
+	}()
+
+	waitSubscribers(t, p, "key"+lockReleasedSuffix, 1)
+	start := time.Now()
+	assert.NoError(t, m.Release(context.Background()))
+
+	select {
+	case m = <-obtained:
+		assert.Less(t, time.Since(start), time.Second)
+	case <-time.After(3 * time.Second):
+		t.Fatal("waiter not woken up by the release")
+	}
+
+	// 获取锁后订阅连接已关闭
+	waitSubscribers(t, p, "key"+lockReleasedSuffix, 0)
+	assert.NoError(t, m.Release(context.Background()))
+}
+
+func TestLocker_Mutex(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	l := NewLocker(p)
+	ctx := context.Background()
+
+	m, err := l.Obtain(ctx, "lock", time.Second, nil)
+	assert.NoError(t, err)
+	s.CheckGet(t, "lock", m.Token())
+	assert.Equal(t, time.Second, s.TTL("lock"))
+
+	_, err = l.Obtain(ctx, "lock", time.Second, nil)
+	assert.ErrorIs(t, err, ErrNotObtained)
+
+	// 其他 token 不能释放或续期他人持有的锁
+	other := newMutex(ctx, l, mutexScripts, "lock", "other", time.Second, time.Now(), &LockOptions{})
+	assert.ErrorIs(t, other.Release(ctx), ErrLockNotHeld)
+	assert.ErrorIs(t, other.Refresh(ctx, time.Minute), ErrLockNotHeld)
+	s.CheckGet(t, "lock", m.Token())
+
+	assert.NoError(t, m.Refresh(ctx, time.Minute))
+	ttl, err := m.TTL(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	assert.NoError(t, m.Release(ctx))
+	assert.False(t, s.Exists("lock"))
+	assert.Error(t, m.Context().Err())
+	assert.ErrorIs(t, m.Release(ctx), ErrLockNotHeld)
+
+	// 锁过期后可以被其他人获取
+	m, err = l.Obtain(ctx, "lock", time.Second, &LockOptions{Token: "token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "token", m.Token())
+	s.FastForward(time.Second)
+	_, err = m.TTL(ctx)
+	assert.ErrorIs(t, err, ErrLockNotHeld)
+
+	_, err = l.Obtain(ctx, "lock", time.Second, nil)
+	assert.NoError(t, err)
+}
+
+func TestMutex_Watchdog(t *testing.T) {
+	node := &fakeNode{accept: true}
+	l := &Locker{nodes: []lockNode{node}, quorum: 1}
+
+	// 获取锁的 ctx 结束后看门狗仍然续期
+	ctx, cancel := context.WithCancel(context.Background())
+	m, err := l.Obtain(ctx, "key", 30*time.Millisecond, &LockOptions{Watchdog: true})
+	assert.NoError(t, err)
+	cancel()
+
+	assert.Eventually(t, func() bool { return node.refreshes() >= 3 }, time.Second, time.Millisecond)
+	assert.NoError(t, m.Context().Err())
+
+	assert.NoError(t, m.Release(context.Background()))
+	assert.ErrorIs(t, m.Context().Err(), context.Canceled)
+	assert.Equal(t, context.Canceled, context.Cause(m.Context()))
+
+	refreshed := node.refreshes()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, refreshed, node.refreshes())
+}
+
+func TestMutex_WatchdogNotHeld(t *testing.T) {
+	node := &fakeNode{accept: true, refreshReject: true}
+	l := &Locker{nodes: []lockNode{node}, quorum: 1}
+
+	lost := make(chan error, 1)
+	opt := &LockOptions{Watchdog: true, OnLost: func(key string, err error) {
+		assert.Equal(t, "key", key)
+		lost <- err
+	}}
+	m, err := l.Obtain(context.Background(), "key", 30*time.Millisecond, opt)
+	assert.NoError(t, err)
+
+	select {
+	case err = <-lost:
+		assert.ErrorIs(t, err, ErrLockNotHeld)
+	case <-time.After(time.Second):
+		t.Fatal("lost lock not reported")
+	}
+	assert.ErrorIs(t, context.Cause(m.Context()), ErrLockNotHeld)
+	assert.Equal(t, 1, node.refreshes())
+}
+
+func TestMutex_WatchdogRenewalFailure(t *testing.T) {
+	netErr := &net.OpError{Op: "read", Err: errors.New("i/o timeout")}
+	node := &fakeNode{accept: true}
+	l := &Locker{nodes: []lockNode{node}, quorum: 1}
+
+	const ttl = 300 * time.Millisecond
+	lost := make(chan error, 1)
+	opt := &LockOptions{Watchdog: true, OnLost: func(_ string, err error) {
+		lost <- err
+	}}
+	start := time.Now()
+	m, err := l.Obtain(context.Background(), "key", ttl, opt)
+	assert.NoError(t, err)
+	node.set(func(n *fakeNode) { n.refreshErr = netErr })
+
+	// 第一次续期失败后锁还有 2/3 ttl 的有效时间，继续重试；
+	// 第二次失败后剩余不足一个续期间隔，必须在锁过期之前宣告丢失
+	select {
+	case err = <-lost:
+		assert.ErrorIs(t, err, netErr)
+	case <-time.After(time.Second):
+		t.Fatal("lost lock not reported")
+	}
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 2*ttl/3)
+	assert.Less(t, elapsed, ttl-ttl/10)
+	assert.Equal(t, 2, node.refreshes())
+	assert.ErrorIs(t, context.Cause(m.Context()), netErr)
+}
+
+func TestLock(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	assert.NoError(t, Lock(p, "lock", 3, 10))
+	s.CheckGet(t, "lock", "1")
+
+	// 锁被持有时重试 maxRetry-1 次，每次间隔 100ms
+	start := time.Now()
+	assert.Error(t, Lock(p, "lock", 3, 10))
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Less(t, time.Since(start), 300*time.Millisecond)
+
+	assert.NoError(t, UnLock(p, "lock"))
+	assert.NoError(t, Lock(p, "lock", 1, 10))
+}
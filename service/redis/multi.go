@@ -1,67 +1,66 @@
 package redis
 
 import (
+	"context"
 	"errors"
-	"net"
 )
 
+// Multi watches the keys of cmds and executes cmds in MULTI/EXEC,
+// ErrTxFailed is returned when a watched key is modified before EXEC.
+//
+// Deprecated: Multi can not read values between WATCH and MULTI, use Pool.Watch instead.
 func (p *Pool) Multi(cmds []map[string]string) (any, error) {
-	// 所有 server 实例异常后，每次都尝试重新测试
-	if p.checkDoTest() {
-		go p.testAll()
-
-		return nil, ServerNonAvailableErr
+	conn, index, err := p.activeConn()
+	if err != nil {
+		return nil, err
 	}
-
-	conn := p.pool.Get()
 	defer conn.Close()
 
 	for _, cmd := range cmds {
-		err := conn.Send("WATCH", cmd["key"])
+		err = conn.Send("WATCH", cmd["key"])
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	err := conn.Send("MULTI")
+	err = conn.Send("MULTI")
 	if err != nil {
 		return nil, err
 	}
 	for _, cmd := range cmds {
 		err = conn.Send(cmd["cmdName"], cmd["key"], cmd["value"])
 		if err != nil {
-			err = conn.Send("DISCARD")
+			_ = conn.Send("DISCARD")
 			return nil, err
 		}
 	}
 
+	// EXEC 发送缓冲区中的全部命令，其耗时即整个事务的耗时
 	var result any
+	info := p.beforeCmd(context.Background(), "MULTI", nil, nil, index)
 	result, err = conn.Do("EXEC")
+	p.afterCmd(info, err)
+	p.feedback(err)
 	if err != nil {
-		var opErr *net.OpError
-		if errors.As(err, &opErr) {
-			p.FailOver()
-			go p.testAll()
-		}
-
-		err = conn.Send("DISCARD")
 		return nil, err
 	}
 
-	p.Recover()
+	// EXEC 返回 nil 表示监视的 key 已被修改，事务未执行
+	if result == nil {
+		return nil, ErrTxFailed
+	}
 
 	return result, nil
 }
 
+// MultiVariable is the same as Multi with variable command arguments, the first argument of each command is watched.
+//
+// Deprecated: use Pool.Watch instead.
 func (p *Pool) MultiVariable(cmds []map[string][]interface{}) (any, error) {
-	// 所有 server 实例异常后，每次都尝试重新测试
-	if p.checkDoTest() {
-		go p.testAll()
-
-		return nil, ServerNonAvailableErr
+	conn, index, err := p.activeConn()
+	if err != nil {
+		return nil, err
 	}
-
-	conn := p.pool.Get()
 	defer conn.Close()
 
 	for _, cmd := range cmds {
@@ -70,14 +69,14 @@ func (p *Pool) MultiVariable(cmds []map[string][]interface{}) (any, error) {
 				return nil, errors.New("param error")
 			}
 
-			err := conn.Send("WATCH", args[0])
+			err = conn.Send("WATCH", args[0])
 			if err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	err := conn.Send("MULTI")
+	err = conn.Send("MULTI")
 	if err != nil {
 		return nil, err
 	}
@@ -85,26 +84,26 @@ func (p *Pool) MultiVariable(cmds []map[string][]interface{}) (any, error) {
 		for cmdName, args := range cmd {
 			err = conn.Send(cmdName, args...)
 			if err != nil {
-				err = conn.Send("DISCARD")
+				_ = conn.Send("DISCARD")
 				return nil, err
 			}
 		}
 	}
 
+	// EXEC 发送缓冲区中的全部命令，其耗时即整个事务的耗时
 	var result any
+	info := p.beforeCmd(context.Background(), "MULTI", nil, nil, index)
 	result, err = conn.Do("EXEC")
+	p.afterCmd(info, err)
+	p.feedback(err)
 	if err != nil {
-		var opErr *net.OpError
-		if errors.As(err, &opErr) {
-			p.FailOver()
-			go p.testAll()
-		}
-
-		err = conn.Send("DISCARD")
 		return nil, err
 	}
 
-	p.Recover()
+	// EXEC 返回 nil 表示监视的 key 已被修改，事务未执行
+	if result == nil {
+		return nil, ErrTxFailed
+	}
 
 	return result, nil
 }
@@ -0,0 +1,170 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Cmd is a command queued by Pipeline or Tx, its reply is available after the commands are executed.
+type Cmd struct {
+	name  string
+	args  []any
+	reply any
+	err   error
+}
+
+func (c *Cmd) Name() string {
+	return c.name
+}
+
+func (c *Cmd) Args() []any {
+	return c.args
+}
+
+// Reply returns the raw reply and the error of the command.
+func (c *Cmd) Reply() (any, error) {
+	return c.reply, c.err
+}
+
+func (c *Cmd) Err() error {
+	return c.err
+}
+
+func (c *Cmd) String() (string, error) {
+	return redis.String(c.reply, c.err)
+}
+
+func (c *Cmd) Bytes() ([]byte, error) {
+	return redis.Bytes(c.reply, c.err)
+}
+
+func (c *Cmd) Int64() (int64, error) {
+	return redis.Int64(c.reply, c.err)
+}
+
+func (c *Cmd) Float64() (float64, error) {
+	return redis.Float64(c.reply, c.err)
+}
+
+func (c *Cmd) Bool() (bool, error) {
+	return redis.Bool(c.reply, c.err)
+}
+
+func (c *Cmd) Strings() ([]string, error) {
+	return redis.Strings(c.reply, c.err)
+}
+
+func (c *Cmd) Values() ([]any, error) {
+	return redis.Values(c.reply, c.err)
+}
+
+func (c *Cmd) StringMap() (map[string]string, error) {
+	return redis.StringMap(c.reply, c.err)
+}
+
+// Pipeline queues commands and sends them to redis in one round trip without MULTI/EXEC,
+// so the commands are not executed atomically.
+// Pipeline is not safe for concurrent use.
+type Pipeline struct {
+	pool *Pool
+	cmds []*Cmd
+}
+
+func (p *Pool) Pipeline() *Pipeline {
+	return &Pipeline{pool: p}
+}
+
+// Send queues a command, the returned Cmd holds the reply after Exec.
+func (pl *Pipeline) Send(cmdStr string, args ...any) *Cmd {
+	cmd := &Cmd{name: cmdStr, args: args}
+	pl.cmds = append(pl.cmds, cmd)
+
+	return cmd
+}
+
+// Len returns the number of queued commands.
+func (pl *Pipeline) Len() int {
+	return len(pl.cmds)
+}
+
+// Discard drops all queued commands.
+func (pl *Pipeline) Discard() {
+	pl.cmds = nil
+}
+
+// Exec flushes the queued commands and reads all replies.
+// It returns the queued commands and the first error, the pipeline is reset afterwards and can be reused.
+// 管道中的命令不会失败重试，避免非幂等的命令被重复执行。
+func (pl *Pipeline) Exec() ([]*Cmd, error) {
+	cmds := pl.cmds
+	pl.cmds = nil
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+
+	pool, index, err := pl.pool.activePool()
+	if err != nil {
+		setCmdsErr(cmds, err)
+		return cmds, err
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+
+	info := pl.pool.beforeCmd(context.Background(), "PIPELINE", nil, cmds, index)
+	err = pipelineExec(conn, cmds)
+	pl.pool.afterCmd(info, err)
+	pl.pool.feedback(err)
+	if err != nil {
+		return cmds, err
+	}
+
+	return cmds, firstCmdErr(cmds)
+}
+
+// pipelineExec sends cmds on conn and receives their replies, it returns the connection error.
+func pipelineExec(conn redis.Conn, cmds []*Cmd) error {
+	for _, cmd := range cmds {
+		if err := conn.Send(cmd.name, cmd.args...); err != nil {
+			setCmdsErr(cmds, err)
+			return err
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		setCmdsErr(cmds, err)
+		return err
+	}
+
+	for i, cmd := range cmds {
+		cmd.reply, cmd.err = conn.Receive()
+		if cmd.err == nil {
+			continue
+		}
+
+		// redis 返回的错误只影响当前命令，连接错误则后续的回复都不可用
+		if _, ok := cmd.err.(redis.Error); !ok {
+			setCmdsErr(cmds[i+1:], cmd.err)
+			return cmd.err
+		}
+	}
+
+	return nil
+}
+
+func setCmdsErr(cmds []*Cmd, err error) {
+	for _, cmd := range cmds {
+		cmd.err = err
+	}
+}
+
+func firstCmdErr(cmds []*Cmd) error {
+	for _, cmd := range cmds {
+		if cmd.err != nil {
+			return cmd.err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// replyConn records the sent commands and answers Receive with the queued replies.
+type replyConn struct {
+	sent     []string
+	replies  []any
+	sendErr  error
+	flushErr error
+}
+
+func (c *replyConn) Close() error { return nil }
+func (c *replyConn) Err() error   { return nil }
+func (c *replyConn) Do(cmd string, args ...any) (any, error) {
+	if cmd == "" {
+		return nil, c.Flush()
+	}
+	if err := c.Send(cmd, args...); err != nil {
+		return nil, err
+	}
+
+	return c.Receive()
+}
+func (c *replyConn) Send(cmd string, _ ...any) error {
+	if c.sendErr != nil {
+		return c.sendErr
+	}
+
+	c.sent = append(c.sent, cmd)
+	return nil
+}
+func (c *replyConn) Flush() error { return c.flushErr }
+func (c *replyConn) Receive() (any, error) {
+	if len(c.replies) == 0 {
+		return nil, &net.OpError{Op: "read", Err: errors.New("connection reset")}
+	}
+
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	if err, ok := reply.(error); ok {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+func newCmds(names ...string) []*Cmd {
+	cmds := make([]*Cmd, 0, len(names))
+	for _, name := range names {
+		cmds = append(cmds, &Cmd{name: name})
+	}
+
+	return cmds
+}
+
+func TestPipelineExec(t *testing.T) {
+	connErr := &net.OpError{Op: "read", Err: errors.New("connection reset")}
+	wrongType := redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	testCases := []struct {
+		name     string
+		conn     *replyConn
+		wantErr  error
+		wantErrs []error
+	}{
+		{
+			name:     "all succeed",
+			conn:     &replyConn{replies: []any{"OK", int64(1), []byte("v")}},
+			wantErrs: []error{nil, nil, nil},
+		},
+		{
+			name:     "reply error only fails its command",
+			conn:     &replyConn{replies: []any{"OK", wrongType, []byte("v")}},
+			wantErrs: []error{nil, wrongType, nil},
+		},
+		{
+			name:     "connection error fails the remaining commands",
+			conn:     &replyConn{replies: []any{wrongType, connErr}},
+			wantErr:  connErr,
+			wantErrs: []error{wrongType, connErr, connErr},
+		},
+		{
+			name:     "send error fails all commands",
+			conn:     &replyConn{sendErr: connErr},
+			wantErr:  connErr,
+			wantErrs: []error{connErr, connErr, connErr},
+		},
+		{
+			name:     "flush error fails all commands",
+			conn:     &replyConn{flushErr: connErr},
+			wantErr:  connErr,
+			wantErrs: []error{connErr, connErr, connErr},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmds := newCmds("SET", "INCR", "GET")
+
+			err := pipelineExec(tc.conn, cmds)
+			assert.Equal(t, tc.wantErr, err)
+
+			errs := make([]error, 0, len(cmds))
+			for _, cmd := range cmds {
+				errs = append(errs, cmd.Err())
+			}
+			assert.Equal(t, tc.wantErrs, errs)
+		})
+	}
+}
+
+func TestPipelineExec_Replies(t *testing.T) {
+	conn := &replyConn{replies: []any{"OK", int64(2), []byte("v")}}
+	cmds := newCmds("SET", "INCR", "GET")
+
+	assert.NoError(t, pipelineExec(conn, cmds))
+	assert.Equal(t, []string{"SET", "INCR", "GET"}, conn.sent)
+
+	s, err := cmds[0].String()
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", s)
+	n, err := cmds[1].Int64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	b, err := cmds[2].Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v"), b)
+	assert.NoError(t, firstCmdErr(cmds))
+}
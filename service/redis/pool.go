@@ -1,9 +1,13 @@
 package redis
 
 import (
+	"context"
 	"errors"
 	"net"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dapings/kit/service/dns"
@@ -19,6 +23,14 @@ var (
 
 	WrongAnswer = errors.New("redis: get wrong answer")
 	EmptyAnswer = errors.New("redis: get empty answer")
+
+	// ErrNil is returned by the typed helpers when the key or the field does not exist,
+	// it is an alias of redis.ErrNil, so errors.Is matches both.
+	ErrNil = redis.ErrNil
+
+	// transientReplies are the codes of the error replies that may succeed on retry,
+	// e.g. the server is loading the dataset, a replica is promoted or a script is running.
+	transientReplies = []string{"LOADING", "READONLY", "BUSY", "MASTERDOWN", "TRYAGAIN"}
 )
 
 type Server struct {
@@ -41,6 +53,16 @@ type Pool struct {
 	// 连接失败熵值加一加到MaxFail进行切换，连接成功熵值减一减到0为止
 	entropy map[int]int
 	servers map[int]string
+
+	// failovers counts the switches of the active redis instance
+	failovers int64
+	hooks     atomic.Pointer[[]Hook]
+	lastErr   map[int]serverError
+
+	// checkNow wakes up the health checker, done stops it
+	checkNow  chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 func (p *Pool) checkDoTest() bool {
@@ -56,25 +78,6 @@ func (p *Pool) checkDoTest() bool {
 	return true
 }
 
-func (p *Pool) testAll() {
-	// 当所有的redis都不可用时，尝试重新探测一个可用的
-	if p.checkDoTest() {
-		for i := range p.pools {
-			go func(index int) {
-				conn := p.pools[index].Get()
-				_, err := conn.Do("PING")
-				var opError *net.OpError
-				if !errors.As(err, &opError) && p.checkDoTest() {
-					p.mu.Lock()
-					// switch redis instance to index
-					p.switchIndex(index)
-					p.mu.Unlock()
-				}
-			}(i)
-		}
-	}
-}
-
 // switchIndex switch redis instance to index.
 func (p *Pool) switchIndex(index int) {
 	// NOTE: 调用前记得加锁
@@ -82,6 +85,10 @@ func (p *Pool) switchIndex(index int) {
 		return
 	}
 
+	if index != p.index {
+		p.failovers++
+	}
+
 	// redis switch to index p.servers[index]
 	p.index = index
 	p.entropy[index] = 0
@@ -102,9 +109,14 @@ func (p *Pool) FailOver() {
 	p.entropy[p.index] = p.entropy[p.index] + 1
 	if p.entropy[p.index] >= MaxFail {
 		p.status[p.index] = false
-		index := p.index + 1
-		if index >= len(p.pools) {
-			index = 0
+
+		// 优先切换到下一个健康的 server，都不健康时按顺序切换
+		index := (p.index + 1) % len(p.pools)
+		for i := 1; i < len(p.pools); i++ {
+			if next := (p.index + i) % len(p.pools); p.status[next] {
+				index = next
+				break
+			}
 		}
 		p.switchIndex(index)
 	}
@@ -123,45 +135,170 @@ func (p *Pool) Recover() {
 	return
 }
 
-func (p *Pool) Do(cmdStr string, args ...any) (reply any, err error) {
-	// actually do the redis commands
-	// 所有server状态异常后，每次都尝试重新测试
+// activePool returns the redis instance pool currently in use and its index.
+func (p *Pool) activePool() (*redis.Pool, int, error) {
+	// 所有server状态异常后，唤醒健康检查尽快探测可用的server
 	if p.checkDoTest() {
-		go p.testAll()
-		return nil, ServerNonAvailableErr
+		p.triggerHealthCheck()
+		return nil, 0, ServerNonAvailableErr
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.pool == nil {
+		return nil, 0, ServerNonAvailableErr
+	}
+
+	return p.pool, p.index, nil
+}
+
+// conn dials a dedicated connection of the active redis instance for subscribing, the caller must close it.
+func (p *Pool) conn() (redis.Conn, error) {
+	conn, _, err := p.dial()
+	return conn, err
+}
+
+// activeConn returns a connection of the active redis instance and its index, the caller must close it.
+func (p *Pool) activeConn() (redis.Conn, int, error) {
+	pool, index, err := p.activePool()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conn := pool.Get()
+	if err = conn.Err(); err != nil {
+		conn.Close()
+		p.feedback(err)
+		return nil, 0, err
+	}
+
+	return conn, index, nil
+}
+
+// dial opens a dedicated connection out of the pool of the active redis instance, e.g. for subscribing,
+// it also returns the index of the instance, the caller must close the connection.
+func (p *Pool) dial() (redis.Conn, int, error) {
+	pool, index, err := p.activePool()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conn, err := pool.Dial()
+	if err != nil {
+		p.feedback(err)
+		return nil, 0, err
+	}
+
+	return conn, index, nil
+}
+
+// activeIndex returns the index of the active redis instance.
+func (p *Pool) activeIndex() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.index
+}
+
+// feedback 根据命令的执行结果调整当前实例的熵值，网络异常时进行主备切换。
+func (p *Pool) feedback(err error) {
+	if err == nil {
+		p.Recover()
+		return
 	}
 
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		p.mu.Lock()
+		p.recordError(p.index, err)
+		p.mu.Unlock()
+
+		p.FailOver()
+		p.triggerHealthCheck()
+	}
+}
+
+// Do executes the command on the active server and retries it up to FailureRetry times on failure,
+// an error reply of the server is returned at once unless it is transient, e.g. LOADING and READONLY.
+func (p *Pool) Do(cmdStr string, args ...any) (reply any, err error) {
+	// actually do the redis commands
 	// 失败重试 FailureRetry 次
-	var conn redis.Conn
-	defer conn.Close()
 	for i := 0; i < FailureRetry; i++ {
-		conn = p.pool.Get()
+		var pool *redis.Pool
+		var index int
+		if pool, index, err = p.activePool(); err != nil {
+			return nil, err
+		}
 
-		if reply, err = conn.Do(cmdStr, args...); err != nil {
-			var opErr *net.OpError
-			if errors.As(err, &opErr) {
-				p.FailOver()
-				go p.testAll()
-			}
+		conn := pool.Get()
+		info := p.beforeCmd(context.Background(), cmdStr, args, nil, index)
+		reply, err = conn.Do(cmdStr, args...)
+		p.afterCmd(info, err)
+		conn.Close()
+		p.feedback(err)
 
+		// 服务端返回的错误（如 WRONGTYPE）重试也不会成功
+		if isPermanentReply(err) {
+			return reply, err
+		}
+
+		if err != nil {
 			time.Sleep(100 * time.Millisecond)
 
 			continue
 		}
 
 		// 重试 i 次后成功
-		p.Recover()
 		break
 	}
 
 	return
 }
 
+// isPermanentReply reports whether err is an error reply of the server which fails again on retry,
+// the reply is transient if its code, i.e. the first word, is one of transientReplies.
+func isPermanentReply(err error) bool {
+	var replyErr redis.Error
+	if !errors.As(err, &replyErr) {
+		return false
+	}
+
+	code, _, _ := strings.Cut(string(replyErr), " ")
+	return !slices.Contains(transientReplies, code)
+}
+
+// DoWithTimeout executes a blocking command with the read timeout, e.g. BLMOVE and XREAD with BLOCK,
+// the timeout must be longer than the blocking time of the command, the command is not retried on failure.
+func (p *Pool) DoWithTimeout(timeout time.Duration, cmdStr string, args ...any) (any, error) {
+	conn, index, err := p.activeConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	info := p.beforeCmd(context.Background(), cmdStr, args, nil, index)
+	reply, err := redis.DoWithTimeout(conn, timeout, cmdStr, args...)
+	p.afterCmd(info, err)
+	p.feedback(err)
+
+	return reply, err
+}
+
 func (p *Pool) Close() error {
+	p.closeOnce.Do(func() {
+		if p.done != nil {
+			close(p.done)
+		}
+	})
+
 	for i := range p.pools {
 		p.pools[i].Close()
 	}
+
+	p.mu.Lock()
 	p.pool = nil
+	p.mu.Unlock()
 
 	return nil
 }
@@ -172,27 +309,50 @@ func NewRedisPool(s []Server) (*Pool, error) {
 	}
 
 	p := &Pool{
-		status:  make(map[int]bool),
-		entropy: make(map[int]int),
-		servers: make(map[int]string),
+		mu:       &sync.RWMutex{},
+		status:   make(map[int]bool),
+		entropy:  make(map[int]int),
+		servers:  make(map[int]string),
+		lastErr:  make(map[int]serverError),
+		checkNow: make(chan struct{}, 1),
+		done:     make(chan struct{}),
 	}
 
+	// 各 map 以 pools 的下标为 key，启动时不可达的 server 也保留，由健康检查在恢复后启用
 	for i := range s {
-		rp, _ := newTimeoutPool(s[i], 1*time.Second, 2*time.Second, 2*time.Second)
-		if _, err := rp.Dial(); err != nil {
+		rp, err := newTimeoutPool(s[i], 1*time.Second, 2*time.Second, 2*time.Second)
+		if err != nil {
 			continue
 		}
 
+		index := len(p.pools)
 		p.pools = append(p.pools, rp)
-		p.entropy[i] = 0
-		p.status[i] = true
-		p.servers[i] = s[i].Host
+		p.entropy[index] = 0
+		p.servers[index] = s[i].Host
+
+		conn, err := rp.Dial()
+		if err != nil {
+			p.recordError(index, err)
+			continue
+		}
+		conn.Close()
+
+		p.status[index] = true
 	}
 
-	p.index = 0
-	if len(p.pools) > p.index {
-		p.pool = p.pools[p.index]
+	p.index = -1
+	for i := range p.pools {
+		if p.status[i] {
+			p.switchIndex(i)
+			break
+		}
 	}
+	if p.index < 0 {
+		p.index = 0
+	}
+	p.failovers = 0
+
+	go p.runHealthCheck()
 
 	return p, nil
 }
@@ -1 +1,180 @@
 package redis
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// pingConn answers PING unless its server is down.
+type pingConn struct {
+	down *atomic.Bool
+}
+
+func (c pingConn) Close() error { return nil }
+func (c pingConn) Err() error   { return nil }
+func (c pingConn) Do(cmd string, _ ...any) (any, error) {
+	if c.down.Load() {
+		return nil, &net.OpError{Op: "read", Err: errors.New("connection reset")}
+	}
+
+	return "PONG", nil
+}
+func (c pingConn) Send(string, ...any) error { return nil }
+func (c pingConn) Flush() error              { return nil }
+func (c pingConn) Receive() (any, error)     { return nil, errors.New("not supported") }
+
+func newTestPool(downs ...*atomic.Bool) *Pool {
+	p := &Pool{
+		mu:       &sync.RWMutex{},
+		status:   make(map[int]bool),
+		entropy:  make(map[int]int),
+		servers:  make(map[int]string),
+		checkNow: make(chan struct{}, 1),
+	}
+
+	for i, down := range downs {
+		p.pools = append(p.pools, &redis.Pool{
+			Dial: func() (redis.Conn, error) {
+				if down.Load() {
+					return nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+				}
+				return pingConn{down: down}, nil
+			},
+		})
+		p.servers[i] = "server" + strconv.Itoa(i)
+		p.status[i] = !down.Load()
+	}
+	p.switchIndex(0)
+	p.failovers = 0
+
+	return p
+}
+
+// newMiniredisPool returns a Pool on an in-memory redis server, which also runs the Lua scripts.
+func newMiniredisPool(t *testing.T) (*Pool, *miniredis.Miniredis) {
+	t.Helper()
+
+	s := miniredis.RunT(t)
+	p, err := NewRedisPool([]Server{{Host: s.Host(), Port: s.Port()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+
+	return p, s
+}
+
+func TestPool_CheckServers(t *testing.T) {
+	var down0, down1 atomic.Bool
+	p := newTestPool(&down0, &down1)
+
+	down0.Store(true)
+	for i := 0; i < MaxFail; i++ {
+		p.checkServers()
+	}
+
+	stats := p.Stats()
+	assert.Equal(t, 1, stats.Index)
+	assert.Equal(t, int64(1), stats.FailOvers)
+	assert.False(t, stats.Servers[0].Healthy)
+	assert.Error(t, stats.Servers[0].LastError)
+	assert.True(t, stats.Servers[1].Active)
+	assert.True(t, stats.Servers[1].Healthy)
+
+	down0.Store(false)
+	p.checkServers()
+	stats = p.Stats()
+	assert.True(t, stats.Servers[0].Healthy)
+	assert.Equal(t, 1, stats.Index)
+}
+
+func TestPool_CheckServersAllDown(t *testing.T) {
+	var down0, down1 atomic.Bool
+	p := newTestPool(&down0, &down1)
+
+	p.mu.Lock()
+	p.status[0], p.status[1] = false, false
+	p.mu.Unlock()
+	down0.Store(true)
+
+	_, _, err := p.activePool()
+	assert.Equal(t, ServerNonAvailableErr, err)
+	assert.Len(t, p.checkNow, 1)
+
+	p.checkServers()
+	pool, index, err := p.activePool()
+	assert.NoError(t, err)
+	assert.Same(t, p.pools[1], pool)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, int64(1), p.Stats().FailOvers)
+}
+
+func TestPool_DoRetry(t *testing.T) {
+	wrongType := redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	loading := redis.Error("LOADING Redis is loading the dataset in memory")
+	readOnly := redis.Error("READONLY You can't write against a read only replica.")
+	busyKey := redis.Error("BUSYKEY Target key name already exists.")
+
+	testCases := []struct {
+		name     string
+		replies  []any
+		wantSent int
+		want     any
+		wantErr  error
+	}{
+		{
+			name:     "permanent error is not retried",
+			replies:  []any{wrongType},
+			wantSent: 1,
+			wantErr:  wrongType,
+		},
+		{
+			name:     "BUSYKEY is not BUSY",
+			replies:  []any{busyKey},
+			wantSent: 1,
+			wantErr:  busyKey,
+		},
+		{
+			name:     "transient error is retried",
+			replies:  []any{loading, "OK"},
+			wantSent: 2,
+			want:     "OK",
+		},
+		{
+			name:     "transient error until the retries run out",
+			replies:  []any{readOnly, readOnly, readOnly},
+			wantSent: FailureRetry,
+			wantErr:  readOnly,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var down atomic.Bool
+			p := newTestPool(&down)
+			conn := &replyConn{replies: tc.replies}
+			p.pools[0].Dial = func() (redis.Conn, error) { return conn, nil }
+
+			reply, err := p.Do("SET", "key", "value")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, reply)
+			assert.Len(t, conn.sent, tc.wantSent)
+		})
+	}
+}
+
+func TestErrNil(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+
+	_, err := redis.String(p.Do("GET", "missing"))
+	assert.ErrorIs(t, err, ErrNil)
+	assert.ErrorIs(t, err, redis.ErrNil)
+}
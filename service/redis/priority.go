@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Priority is the priority of a job in PriorityQueue, a higher priority is dequeued first.
+type Priority int
+
+const (
+	PriorityLow    Priority = 0
+	PriorityNormal Priority = 5
+	PriorityHigh   Priority = 9
+
+	// MaxPriority is the highest priority supported.
+	MaxPriority = PriorityHigh
+)
+
+const (
+	prioritySuffix = "_priority"
+	sequenceSuffix = "_seq"
+
+	// prioritySpan is the score range of a priority, the sequence in a priority wraps around within the span.
+	prioritySpan = 1e12
+)
+
+// 优先级队列使用有序集合 <queue>_priority 存储 key，score 为 (MaxPriority-priority)*1e12+序号，
+// 分数越小越先出队，同一优先级内先进先出；数据存储在 <queue>_priority_htable，与 EnQueue 一样按 key 合并，
+// 与同名的普通队列互不影响。ZADD 的 LT 选项需要 Redis 6.2 及以上版本。
+const (
+	// ScriptPriorityEnQueue stores the data and adds the key with the score if it is new,
+	// the score of a queued key is only lowered, i.e. its priority is raised, ZADD LT requires Redis 6.2.
+	// KEYS: priority zset, htable, sequence
+	// ARGV: key, data, base score of the priority, span
+	ScriptPriorityEnQueue = `
+		local added = redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+		local score = ARGV[3] + redis.call("INCR", KEYS[3]) % ARGV[4]
+		if added == 1 then
+			redis.call("ZADD", KEYS[1], score, ARGV[1])
+		else
+			redis.call("ZADD", KEYS[1], "LT", score, ARGV[1])
+		end
+		return added
+	`
+	// ScriptPriorityDeQueue pops the key with the highest priority and its data.
+	// KEYS: priority zset, htable
+	ScriptPriorityDeQueue = `
+		local popped = redis.call("ZPOPMIN", KEYS[1])
+		if #popped == 0 then
+			return false
+		end
+		local data = redis.call("HGET", KEYS[2], popped[1])
+		redis.call("HDEL", KEYS[2], popped[1])
+		return {popped[1], data}
+	`
+)
+
+var (
+	scriptPriorityEnQueue = RegisterScript("queue.priority_enqueue", 3, ScriptPriorityEnQueue)
+	scriptPriorityDeQueue = RegisterScript("queue.priority_dequeue", 2, ScriptPriorityDeQueue)
+)
+
+// PriorityQueue is a queue whose jobs are dequeued by priority and then in the enqueued order,
+// the data of a key already in the queue is merged like EnQueue.
+// It requires Redis 6.2 or later.
+type PriorityQueue struct {
+	pool *Pool
+	name string
+}
+
+func NewPriorityQueue(pool *Pool, queueName string) *PriorityQueue {
+	return &PriorityQueue{pool: pool, name: queueName}
+}
+
+func (q *PriorityQueue) Name() string {
+	return q.name
+}
+
+// EnQueue adds a job with the priority, it reports whether the key is new,
+// for a key already in the queue, the data is merged and the priority is raised if the new one is higher.
+func (q *PriorityQueue) EnQueue(key string, data []byte, priority Priority) (bool, error) {
+	if key == "" {
+		return false, fmt.Errorf("queue %s using invalid key: %s", q.name, key)
+	}
+
+	if priority < PriorityLow || priority > MaxPriority {
+		return false, fmt.Errorf("queue %s using invalid priority: %d", q.name, priority)
+	}
+
+	added, err := redis.Int(q.pool.EvalScript(scriptPriorityEnQueue, q.name+prioritySuffix, q.name+prioritySuffix+hTable,
+		q.name+sequenceSuffix, key, data, int64(MaxPriority-priority)*prioritySpan, int64(prioritySpan)))
+	if err != nil {
+		return false, err
+	}
+
+	return added == 1, nil
+}
+
+// DeQueue pops the job with the highest priority, ErrQueueEmpty is returned if the queue is empty.
+func (q *PriorityQueue) DeQueue() (*Job, error) {
+	result, err := q.pool.EvalScript(scriptPriorityDeQueue, q.name+prioritySuffix, q.name+prioritySuffix+hTable)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, ErrQueueEmpty
+	}
+
+	elems, err := redis.Values(result, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) != 2 {
+		return nil, WrongAnswer
+	}
+
+	job := &Job{Queue: q.name}
+	if job.Key, err = redis.String(elems[0], nil); err != nil {
+		return nil, err
+	}
+	if elems[1] != nil {
+		if job.Data, err = redis.Bytes(elems[1], nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return job, nil
+}
+
+// Len returns the number of jobs in the queue.
+func (q *PriorityQueue) Len() (int64, error) {
+	return redis.Int64(q.pool.Do("ZCARD", q.name+prioritySuffix))
+}
@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	q := NewPriorityQueue(p, "queue")
+
+	job, err := q.DeQueue()
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+	assert.Nil(t, job)
+
+	jobs := []struct {
+		key      string
+		priority Priority
+	}{
+		{"low", PriorityLow},
+		{"normal1", PriorityNormal},
+		{"high", PriorityHigh},
+		{"normal2", PriorityNormal},
+	}
+	for _, j := range jobs {
+		added, err := q.EnQueue(j.key, []byte(j.key), j.priority)
+		assert.NoError(t, err)
+		assert.True(t, added)
+	}
+
+	n, err := q.Len()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+
+	// 数据与普通队列分开存储
+	assert.False(t, s.Exists("queue"+hTable))
+
+	// 按优先级出队，同一优先级先进先出
+	var keys []string
+	for {
+		job, err := q.DeQueue()
+		if err != nil {
+			assert.ErrorIs(t, err, ErrQueueEmpty)
+			break
+		}
+		assert.Equal(t, "queue", job.Queue)
+		assert.Equal(t, []byte(job.Key), job.Data)
+		keys = append(keys, job.Key)
+	}
+	assert.Equal(t, []string{"high", "normal1", "normal2", "low"}, keys)
+	assert.False(t, s.Exists("queue"+prioritySuffix+hTable))
+}
+
+func TestPriorityQueue_Merge(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+	q := NewPriorityQueue(p, "queue")
+
+	_, err := q.EnQueue("a", []byte("1"), PriorityNormal)
+	assert.NoError(t, err)
+	_, err = q.EnQueue("b", []byte("2"), PriorityNormal)
+	assert.NoError(t, err)
+
+	// 较低的优先级只合并数据，不降低优先级
+	added, err := q.EnQueue("a", []byte("3"), PriorityLow)
+	assert.NoError(t, err)
+	assert.False(t, added)
+
+	// 较高的优先级提升已有 key 的优先级
+	added, err = q.EnQueue("b", []byte("4"), PriorityHigh)
+	assert.NoError(t, err)
+	assert.False(t, added)
+
+	n, err := q.Len()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	job, err := q.DeQueue()
+	assert.NoError(t, err)
+	assert.Equal(t, "b", job.Key)
+	assert.Equal(t, []byte("4"), job.Data)
+	job, err = q.DeQueue()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", job.Key)
+	assert.Equal(t, []byte("3"), job.Data)
+}
+
+func TestPriorityQueue_Separate(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+	q := NewPriorityQueue(p, "queue")
+
+	// 同名的普通队列与优先级队列互不影响
+	assert.NoError(t, EnQueue(p, "queue", "a", []byte("plain")))
+	added, err := q.EnQueue("a", []byte("priority"), PriorityNormal)
+	assert.NoError(t, err)
+	assert.True(t, added)
+
+	job, err := q.DeQueue()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("priority"), job.Data)
+	key, data := DeQueue(p, "queue")
+	assert.Equal(t, "a", key)
+	assert.Equal(t, []byte("plain"), data)
+}
+
+func TestPriorityQueue_Invalid(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+	q := NewPriorityQueue(p, "queue")
+
+	_, err := q.EnQueue("", nil, PriorityNormal)
+	assert.Error(t, err)
+	_, err = q.EnQueue("a", nil, PriorityLow-1)
+	assert.Error(t, err)
+	_, err = q.EnQueue("a", nil, MaxPriority+1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,319 @@
+package redis
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrSubscriberClosed is returned when operating a closed Subscriber.
+var ErrSubscriberClosed = errors.New("redis: subscriber closed")
+
+// Publish posts the message to the channel, it returns the number of clients received the message.
+func (p *Pool) Publish(channel string, message any) (int64, error) {
+	return redis.Int64(p.Do("PUBLISH", channel, message))
+}
+
+// Message is a message received from a subscribed channel.
+type Message struct {
+	Channel string
+	// Pattern is the matched pattern if the message is received by PSubscribe.
+	Pattern string
+	Data    []byte
+}
+
+// SubscriberOptions configures a Subscriber, the zero value is valid.
+type SubscriberOptions struct {
+	// PingInterval is the interval to check the health of the connection while anything is subscribed, default 5s.
+	PingInterval time.Duration
+	// Reconnect decides the backoff between reconnections, default exponential backoff from 100ms to 5s,
+	// it is reset after a message or a subscription confirmation is received on the connection.
+	Reconnect RetryStrategy
+	// BufferSize is the buffer size of the message channel, default 100.
+	BufferSize int
+	// OnError is called with the connection errors before reconnecting.
+	OnError func(err error)
+}
+
+// Subscriber receives the messages of the subscribed channels and patterns on a dedicated connection,
+// it reconnects and resubscribes all of them when the connection is broken or the Pool fails over to another server.
+type Subscriber struct {
+	pool     *Pool
+	opts     SubscriberOptions
+	messages chan *Message
+
+	mu       sync.Mutex
+	conn     *redis.PubSubConn
+	channels map[string]struct{}
+	patterns map[string]struct{}
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	done      chan struct{}
+}
+
+// NewSubscriber returns a Subscriber on the active server of the pool, opts may be nil.
+func (p *Pool) NewSubscriber(opts *SubscriberOptions) *Subscriber {
+	s := &Subscriber{
+		pool:     p,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+
+	if s.opts.PingInterval <= 0 {
+		s.opts.PingInterval = 5 * time.Second
+	}
+	if s.opts.Reconnect == nil {
+		s.opts.Reconnect = ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+	}
+	if s.opts.BufferSize <= 0 {
+		s.opts.BufferSize = 100
+	}
+	s.messages = make(chan *Message, s.opts.BufferSize)
+
+	go s.run()
+
+	return s
+}
+
+// Channel returns the channel of the received messages, it is closed after the Subscriber is closed.
+func (s *Subscriber) Channel() <-chan *Message {
+	return s.messages
+}
+
+// Subscribe subscribes to the channels, they are resubscribed after reconnection.
+func (s *Subscriber) Subscribe(channels ...string) error {
+	return s.update(s.channels, channels, true, func(conn *redis.PubSubConn, args ...any) error {
+		return conn.Subscribe(args...)
+	})
+}
+
+// PSubscribe subscribes to the patterns, they are resubscribed after reconnection.
+func (s *Subscriber) PSubscribe(patterns ...string) error {
+	return s.update(s.patterns, patterns, true, func(conn *redis.PubSubConn, args ...any) error {
+		return conn.PSubscribe(args...)
+	})
+}
+
+// Unsubscribe unsubscribes from the channels.
+func (s *Subscriber) Unsubscribe(channels ...string) error {
+	return s.update(s.channels, channels, false, func(conn *redis.PubSubConn, args ...any) error {
+		return conn.Unsubscribe(args...)
+	})
+}
+
+// PUnsubscribe unsubscribes from the patterns.
+func (s *Subscriber) PUnsubscribe(patterns ...string) error {
+	return s.update(s.patterns, patterns, false, func(conn *redis.PubSubConn, args ...any) error {
+		return conn.PUnsubscribe(args...)
+	})
+}
+
+// update records the subscriptions and sends the command on the current connection,
+// a send error is ignored since the subscriptions are restored after reconnection.
+func (s *Subscriber) update(set map[string]struct{}, names []string, add bool, send func(conn *redis.PubSubConn, args ...any) error) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closed:
+		return ErrSubscriberClosed
+	default:
+	}
+
+	for _, name := range names {
+		if add {
+			set[name] = struct{}{}
+		} else {
+			delete(set, name)
+		}
+	}
+
+	if s.conn != nil {
+		_ = send(s.conn, redis.Args{}.AddFlat(names)...)
+	}
+
+	return nil
+}
+
+// Close closes the connection and the message channel.
+func (s *Subscriber) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		close(s.closed)
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.mu.Unlock()
+	})
+
+	<-s.done
+
+	return nil
+}
+
+func (s *Subscriber) run() {
+	defer close(s.done)
+	defer close(s.messages)
+
+	for attempt := 1; ; attempt++ {
+		conn, index, err := s.connect()
+		healthy := false
+		if err == nil {
+			healthy, err = s.receive(conn, index)
+		}
+
+		select {
+		case <-s.closed:
+			return
+		default:
+		}
+
+		if err != nil && s.opts.OnError != nil {
+			s.opts.OnError(err)
+		}
+
+		// 连接收到过消息或订阅确认后断开时立即重连，否则按重试策略等待，避免连接建立后即失败时反复重连
+		if healthy {
+			attempt = 0
+			continue
+		}
+
+		backoff := s.opts.Reconnect.Backoff(attempt)
+		if backoff < 0 {
+			backoff = 0
+		}
+
+		select {
+		case <-s.closed:
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// subscribed reports whether any channel or pattern is subscribed, s.mu must be held.
+func (s *Subscriber) subscribed() bool {
+	return len(s.channels) > 0 || len(s.patterns) > 0
+}
+
+// connect subscribes all channels and patterns on a new connection of the active server.
+func (s *Subscriber) connect() (*redis.PubSubConn, int, error) {
+	c, index, err := s.pool.dial()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conn := &redis.PubSubConn{Conn: c}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closed:
+		conn.Close()
+		return nil, 0, ErrSubscriberClosed
+	default:
+	}
+
+	if len(s.channels) > 0 {
+		err = conn.Subscribe(redis.Args{}.AddFlat(mapKeys(s.channels))...)
+	}
+	if err == nil && len(s.patterns) > 0 {
+		err = conn.PSubscribe(redis.Args{}.AddFlat(mapKeys(s.patterns))...)
+	}
+	if err != nil {
+		conn.Close()
+		s.pool.feedback(err)
+		return nil, 0, err
+	}
+
+	s.conn = conn
+
+	return conn, index, nil
+}
+
+// receive delivers the messages until the connection is broken,
+// it reports whether the connection is healthy, i.e. a message or a subscription confirmation is received.
+// 单独的协程定时发送 PING，订阅时读超时为两个 PING 周期；未订阅任何频道时连接处于普通模式，PING 的回复无法按订阅消息解析，
+// 此时不发送 PING 也不设置读超时。连接异常或 Pool 已切换到其他 server 时关闭连接，读取随即返回错误，由 run 重新连接并订阅。
+func (s *Subscriber) receive(conn *redis.PubSubConn, index int) (bool, error) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		ticker := time.NewTicker(s.opts.PingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			var err error
+			s.mu.Lock()
+			if s.subscribed() {
+				err = conn.Ping("")
+			}
+			s.mu.Unlock()
+			if err != nil || s.pool.activeIndex() != index {
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	healthy := false
+	for {
+		s.mu.Lock()
+		var timeout time.Duration
+		if s.subscribed() {
+			timeout = 2 * s.opts.PingInterval
+		}
+		s.mu.Unlock()
+
+		switch v := conn.ReceiveWithTimeout(timeout).(type) {
+		case redis.Message:
+			healthy = true
+			select {
+			case s.messages <- &Message{Channel: v.Channel, Pattern: v.Pattern, Data: v.Data}:
+			case <-s.closed:
+				return healthy, ErrSubscriberClosed
+			}
+		case redis.Subscription:
+			healthy = true
+		case error:
+			s.pool.feedback(v)
+			return healthy, v
+		}
+	}
+}
+
+func mapKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
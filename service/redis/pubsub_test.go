@@ -0,0 +1,162 @@
+package redis
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// receiveMessage waits for a message of the subscriber.
+func receiveMessage(t *testing.T, sub *Subscriber) *Message {
+	t.Helper()
+
+	select {
+	case msg := <-sub.Channel():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+// waitSubscribers waits until the channel has n subscribers on the server.
+func waitSubscribers(t *testing.T, p *Pool, channel string, n int64) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		values, err := redis.Values(p.Do("PUBSUB", "NUMSUB", channel))
+		if err != nil || len(values) != 2 {
+			return false
+		}
+		count, err := redis.Int64(values[1], nil)
+		return err == nil && count == n
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSubscriber(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	sub := p.NewSubscriber(nil)
+	assert.NoError(t, sub.Subscribe("news"))
+	assert.NoError(t, sub.PSubscribe("event.*"))
+	waitSubscribers(t, p, "news", 1)
+	assert.Eventually(t, func() bool { return s.PubSubNumPat() == 1 }, time.Second, 10*time.Millisecond)
+
+	n, err := p.Publish("news", "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	msg := receiveMessage(t, sub)
+	assert.Equal(t, &Message{Channel: "news", Data: []byte("hello")}, msg)
+
+	_, err = p.Publish("event.login", "user")
+	assert.NoError(t, err)
+	msg = receiveMessage(t, sub)
+	assert.Equal(t, &Message{Channel: "event.login", Pattern: "event.*", Data: []byte("user")}, msg)
+
+	assert.NoError(t, sub.Unsubscribe("news"))
+	waitSubscribers(t, p, "news", 0)
+
+	assert.NoError(t, sub.Close())
+	_, ok := <-sub.Channel()
+	assert.False(t, ok)
+	assert.ErrorIs(t, sub.Subscribe("news"), ErrSubscriberClosed)
+}
+
+func TestSubscriber_Resubscribe(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	var errs atomic.Int64
+	sub := p.NewSubscriber(&SubscriberOptions{
+		Reconnect: ConstantBackoff(10 * time.Millisecond),
+		OnError:   func(err error) { errs.Add(1) },
+	})
+	t.Cleanup(func() { _ = sub.Close() })
+	assert.NoError(t, sub.Subscribe("news"))
+	waitSubscribers(t, p, "news", 1)
+
+	// 服务端断开连接后重新连接并订阅
+	s.Close()
+	assert.NoError(t, s.Restart())
+	waitSubscribers(t, p, "news", 1)
+	assert.Positive(t, errs.Load())
+
+	_, err := p.Publish("news", "again")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("again"), receiveMessage(t, sub).Data)
+}
+
+func TestSubscriber_Idle(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+
+	// 未订阅任何频道时不发送 PING，连接保持可用
+	var errs atomic.Int64
+	sub := p.NewSubscriber(&SubscriberOptions{
+		PingInterval: 10 * time.Millisecond,
+		OnError:      func(err error) { errs.Add(1) },
+	})
+	t.Cleanup(func() { _ = sub.Close() })
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(t, errs.Load())
+
+	// 订阅后按 PING 周期检查连接，空闲的订阅不会超时
+	assert.NoError(t, sub.Subscribe("news"))
+	waitSubscribers(t, p, "news", 1)
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(t, errs.Load())
+
+	// 取消全部订阅后回到普通模式
+	assert.NoError(t, sub.Unsubscribe("news"))
+	waitSubscribers(t, p, "news", 0)
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(t, errs.Load())
+}
+
+func TestSubscriber_ReconnectBackoff(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+
+	// 连接建立后立即失败时按重试策略等待，而不是立即重连
+	dial := p.pools[0].Dial
+	p.pools[0].Dial = func() (redis.Conn, error) {
+		conn, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		conn.Close()
+		return conn, nil
+	}
+
+	var mu sync.Mutex
+	var failures []time.Time
+	var attempts []int
+	sub := p.NewSubscriber(&SubscriberOptions{
+		Reconnect: RetryFunc(func(attempt int) time.Duration {
+			mu.Lock()
+			attempts = append(attempts, attempt)
+			mu.Unlock()
+			return 30 * time.Millisecond
+		}),
+		OnError: func(err error) {
+			mu.Lock()
+			failures = append(failures, time.Now())
+			mu.Unlock()
+		},
+	})
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, sub.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NotEmpty(t, failures)
+	assert.LessOrEqual(t, len(failures), 8)
+	for i := 1; i < len(failures); i++ {
+		assert.GreaterOrEqual(t, failures[i].Sub(failures[i-1]), 30*time.Millisecond)
+	}
+	for i, attempt := range attempts {
+		assert.Equal(t, i+1, attempt)
+	}
+}
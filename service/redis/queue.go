@@ -1,16 +1,115 @@
 package redis
 
 import (
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/gomodule/redigo/redis"
 )
 
 const (
 	hTable           = "_htable"
 	retryQueueSuffix = "_retry"
-	lockKeySuffix    = "_lock"
+	attemptsSuffix   = "_attempts"
+	deadLetterSuffix = "_dead"
+)
+
+// 失败的任务按重试策略延时后重新入队：重试任务存储在有序集合 <queue>_retry 中，score 为重试时间（毫秒），
+// 数据存储在 <queue>_retry_htable；每个 key 的失败次数记录在 <queue>_attempts 中，
+// 超过最大次数后移入死信队列（list <queue>_dead，数据存储在 <queue>_dead_htable）。
+// 队列使用 list 存储 key，<queue>_htable 存储数据，入队与出队均通过脚本原子地完成。
+const (
+	// ScriptEnQueue adds the key to the queue unless the queue length reaches the hard limit ARGV[3],
+	// the data of a key already in the queue is merged without checking the limit.
+	// It returns {1 if added, 0 if merged, -1 if the queue is full, the queue length}.
+	// KEYS: queue, queue htable
+	// ARGV: key, data, hard limit or 0 for unlimited
+	ScriptEnQueue = `
+		if redis.call("HEXISTS", KEYS[2], ARGV[1]) == 1 then
+			redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+			return {0, redis.call("LLEN", KEYS[1])}
+		end
+		local length = redis.call("LLEN", KEYS[1])
+		local limit = tonumber(ARGV[3])
+		if limit > 0 and length >= limit then
+			return {-1, length}
+		end
+		redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+		return {1, redis.call("RPUSH", KEYS[1], ARGV[1])}
+	`
+	// ScriptDeQueue pops the first key of the queue with its data, it returns {key, data, the queue length}.
+	// KEYS: queue, queue htable
+	ScriptDeQueue = `
+		local key = redis.call("LPOP", KEYS[1])
+		if not key then
+			return false
+		end
+		local data = redis.call("HGET", KEYS[2], key)
+		redis.call("HDEL", KEYS[2], key)
+		return {key, data, redis.call("LLEN", KEYS[1])}
+	`
+	// ScriptRetry records a failure of the key, then schedules it to be retried at ARGV[3],
+	// or moves it to the dead letter list if ARGV[3] is negative or the failures exceed ARGV[4].
+	// It returns 1 if the key is dead lettered, otherwise 0.
+	// KEYS: attempts hash, retry zset, retry htable, dead list, dead htable
+	// ARGV: key, data, retry time in milliseconds, max attempts
+	ScriptRetry = `
+		local attempts = redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+		if tonumber(ARGV[3]) < 0 or attempts > tonumber(ARGV[4]) then
+			redis.call("HDEL", KEYS[1], ARGV[1])
+			if redis.call("HSET", KEYS[5], ARGV[1], ARGV[2]) == 1 then
+				redis.call("RPUSH", KEYS[4], ARGV[1])
+			end
+			return 1
+		end
+		redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+		redis.call("HSET", KEYS[3], ARGV[1], ARGV[2])
+		return 0
+	`
+	// ScriptRequeueDeadLetter moves at most limit keys from the dead letter list back to the queue.
+	// KEYS: queue, queue htable, dead list, dead htable, attempts hash
+	// ARGV: limit
+	ScriptRequeueDeadLetter = `
+		local n = 0
+		while n < tonumber(ARGV[1]) do
+			local key = redis.call("LPOP", KEYS[3])
+			if not key then
+				break
+			end
+			n = n + 1
+			local data = redis.call("HGET", KEYS[4], key)
+			redis.call("HDEL", KEYS[4], key)
+			redis.call("HDEL", KEYS[5], key)
+			if data and redis.call("HSET", KEYS[2], key, data) == 1 then
+				redis.call("RPUSH", KEYS[1], key)
+			end
+		end
+		return n
+	`
+)
+
+var (
+	scriptEnQueue           = RegisterScript("queue.enqueue", 2, ScriptEnQueue)
+	scriptDeQueue           = RegisterScript("queue.dequeue", 2, ScriptDeQueue)
+	scriptRetry             = RegisterScript("queue.retry", 5, ScriptRetry)
+	scriptRequeueDeadLetter = RegisterScript("queue.requeue_dead_letter", 5, ScriptRequeueDeadLetter)
 )
 
+// RetryPolicy decides when a failed job is retried and when it is moved to the dead letter list.
+type RetryPolicy struct {
+	// MaxAttempts is the max failed times of a job before it is moved to the dead letter list.
+	MaxAttempts int
+	// Backoff decides the wait before the attempt-th retry, a negative backoff moves the job to the dead letter list.
+	Backoff RetryStrategy
+}
+
+// DefaultRetryPolicy retries a failed job at most 5 times with exponential backoff from 1 second to 10 minutes.
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 5,
+	Backoff:     ExponentialBackoff(time.Second, 10*time.Minute),
+}
+
 func EnQueueReliably(pool *Pool, queue string, key string, data []byte) (err error) {
 	for {
 		err = EnQueue(pool, queue, key, data)
@@ -24,6 +123,8 @@ func EnQueueReliably(pool *Pool, queue string, key string, data []byte) (err err
 	return
 }
 
+// EnQueue adds the key to the queue and stores data in <queue>_htable, the data of a key already in the queue is merged.
+// ErrQueueFull is returned when the queue reaches the hard limit configured by SetQueueLimit.
 func EnQueue(pool *Pool, queueName, key string, data []byte) error {
 	if pool == nil {
 		return fmt.Errorf("redis pool is empty")
@@ -33,80 +134,131 @@ func EnQueue(pool *Pool, queueName, key string, data []byte) error {
 		return fmt.Errorf("queue %s using invalid key: %s", queueName, key)
 	}
 
-	result, err := pool.HSet(queueName+hTable, key, string(data))
-	if err != nil {
-		return err
+	// 队列长度超过硬限制时，等待队列消费或返回 ErrQueueFull
+	return enQueueLimited(pool, queueName, key, data)
+}
+
+// DeQueue pops the first key of the queue with its data atomically, an empty key is returned if the queue is empty or on error.
+// The popped job is lost if the consumer crashes before processing it, use ReliableQueue to process it at least once.
+func DeQueue(pool *Pool, queueName string) (key string, data []byte) {
+	if pool == nil {
+		return "", nil
 	}
 
-	// 数据合并，队列不重复添加
-	if result == 0 {
-		return nil
+	values, err := redis.Values(pool.EvalScript(scriptDeQueue, queueName, queueName+hTable))
+	if err != nil || len(values) != 3 {
+		return "", nil
 	}
 
-	err = pool.RPush(queueName, []byte(key))
-	if err != nil {
-		return err
+	// 针对每个队列都可配置，见 SetQueueLimit，已出队的数据不丢弃，仅告警
+	if queueLen, err := redis.Int64(values[2], nil); err == nil {
+		getQueueLimit(queueName).reportQueueLen(queueName, queueLen)
 	}
 
-	return nil
+	key, _ = redis.String(values[0], nil)
+	data, _ = redis.Bytes(values[1], nil)
+
+	return key, data
 }
 
-func DeQueue(pool *Pool, queueName string) (key string, data []byte) {
+func CheckLocked(pool *Pool, key string) bool {
+	val, err := pool.Get(key)
+	if err != nil || val != nil {
+		return true
+	}
+
+	return false
+}
+
+// Retry records a failure of the job and schedules it to be requeued after the backoff of policy,
+// the job is moved to the dead letter list <queue>_dead instead after policy.MaxAttempts failures.
+// It reports whether the job is dead lettered, a nil policy means DefaultRetryPolicy.
+func Retry(pool *Pool, queueName, key string, data []byte, policy *RetryPolicy) (dead bool, err error) {
 	if pool == nil {
-		return "", nil
+		return false, fmt.Errorf("redis pool is empty")
 	}
 
-	result, err := pool.LPop(queueName)
-	if err != nil || result == nil {
-		return "", nil
+	if key == "" {
+		return false, fmt.Errorf("queue %s using invalid key: %s", queueName, key)
 	}
 
-	k, ok := result.([]byte)
-	if !ok {
-		return "", nil
+	if policy == nil {
+		policy = DefaultRetryPolicy
 	}
 
-	queueLen, err := pool.LLen(queueName)
-	if err != nil || queueLen == 0 {
-		return "", nil
+	// 失败次数只用于计算退避时间，记录失败与重新调度在脚本中原子地完成
+	attempts, err := Attempts(pool, queueName, key)
+	if err != nil {
+		return false, err
 	}
 
-	// 针对每个队列都可配置，默认100
-	quenueLenLimt := 100
-	if queueLen >= int64(quenueLenLimt) && queueLen < 10*int64(quenueLenLimt) {
-		// warn: queue length is over limit
-		return "", nil
-	} else if queueLen >= 10*int64(quenueLenLimt) {
-		// error: queue length is over limit
-		return "", nil
+	due, err := policy.retryTime(pool, attempts+1)
+	if err != nil {
+		return false, err
 	}
 
-	key = string(k)
-	result, err = pool.HPop(queueName+hTable, key)
+	retry := queueName + retryQueueSuffix
+	deadLetter := queueName + deadLetterSuffix
+	n, err := redis.Int(pool.EvalScript(scriptRetry, queueName+attemptsSuffix, retry, retry+hTable, deadLetter, deadLetter+hTable,
+		key, data, due, policy.MaxAttempts))
+
+	return n == 1, err
+}
+
+// retryTime returns the time in milliseconds of the redis server to retry the attempt-th failure,
+// -1 if the job should be dead lettered.
+func (rp *RetryPolicy) retryTime(pool *Pool, attempt int) (int64, error) {
+	if attempt > rp.MaxAttempts || rp.Backoff == nil {
+		return -1, nil
+	}
+
+	backoff := rp.Backoff.Backoff(attempt)
+	if backoff < 0 {
+		return -1, nil
+	}
+
+	now, err := pool.ServerTime()
 	if err != nil {
-		return "", nil
+		return 0, err
 	}
-	if result == nil {
-		return key, nil
+
+	return now.Add(backoff).UnixMilli(), nil
+}
+
+// Attempts returns the failed times of the job.
+func Attempts(pool *Pool, queueName, key string) (int, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("redis pool is empty")
 	}
 
-	data, ok = result.([]byte)
-	if !ok {
-		return "", nil
+	n, err := redis.Int(pool.Do("HGET", queueName+attemptsSuffix, key))
+	if errors.Is(err, redis.ErrNil) {
+		return 0, nil
 	}
 
-	return
+	return n, err
 }
 
-func CheckLocked(pool *Pool, key string) bool {
-	val, err := pool.Get(key)
-	if err != nil || val != nil {
-		return true
+// PromoteRetries moves at most limit jobs whose retry time is due to the queue, it returns the number of due jobs.
+func PromoteRetries(pool *Pool, queueName string, limit int) (int, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("redis pool is empty")
 	}
 
-	return false
+	now, err := pool.ServerTime()
+	if err != nil {
+		return 0, err
+	}
+
+	retry := queueName + retryQueueSuffix
+
+	return redis.Int(pool.EvalScript(scriptPromoteDelayed,
+		queueName, queueName+hTable, retry, retry+hTable, now.UnixMilli(), limit))
 }
 
+// RetryAll only reports whether the key exists or reading it fails, it moves no job.
+//
+// Deprecated: RetryAll does not retry anything, use PromoteAllRetries to move all jobs waiting for retry to the queue.
 func RetryAll(pool *Pool, key string) bool {
 	val, err := pool.Get(key)
 	if err != nil || val != nil {
@@ -115,3 +267,37 @@ func RetryAll(pool *Pool, key string) bool {
 
 	return false
 }
+
+// PromoteAllRetries moves all jobs waiting for retry to the queue immediately, it returns the number of moved jobs.
+func PromoteAllRetries(pool *Pool, queueName string) (int, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("redis pool is empty")
+	}
+
+	retry := queueName + retryQueueSuffix
+
+	return redis.Int(pool.EvalScript(scriptPromoteDelayed,
+		queueName, queueName+hTable, retry, retry+hTable, "+inf", -1))
+}
+
+// Requeue moves at most limit jobs from the dead letter list back to the queue and clears their attempts,
+// it returns the number of moved jobs.
+func Requeue(pool *Pool, queueName string, limit int) (int, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("redis pool is empty")
+	}
+
+	dead := queueName + deadLetterSuffix
+
+	return redis.Int(pool.EvalScript(scriptRequeueDeadLetter,
+		queueName, queueName+hTable, dead, dead+hTable, queueName+attemptsSuffix, limit))
+}
+
+// DeadLetterLen returns the number of jobs in the dead letter list.
+func DeadLetterLen(pool *Pool, queueName string) (int64, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("redis pool is empty")
+	}
+
+	return pool.LLen(queueName + deadLetterSuffix)
+}
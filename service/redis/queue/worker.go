@@ -0,0 +1,201 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dapings/kit/service/redis"
+)
+
+// Handler processes a job, the job is acknowledged if it returns nil, otherwise it is retried.
+type Handler func(ctx context.Context, job *redis.Job) error
+
+// Middleware wraps a Handler, e.g. for logging and metrics.
+type Middleware func(next Handler) Handler
+
+// Options configures a Worker, the zero value is valid.
+type Options struct {
+	// Concurrency is the number of concurrent handlers, default 1.
+	Concurrency int
+	// Consumer is the name prefix of the consumers, each handler goroutine consumes as <Consumer>-<i>.
+	// It must be unique among the workers of the queue, default <hostname>-<pid>,
+	// the consumers of exited workers are unregistered by the reaper once their jobs are requeued.
+	Consumer string
+	// PollTimeout is the blocking time of dequeuing, default 1s.
+	PollTimeout time.Duration
+	// JobTimeout cancels the context of a handler after the timeout, no timeout if zero.
+	// It should be shorter than the visibility timeout of the queue, otherwise the job may be delivered twice.
+	JobTimeout time.Duration
+	// RetryPolicy schedules the failed jobs, nil means redis.DefaultRetryPolicy.
+	RetryPolicy *redis.RetryPolicy
+	// MaintainInterval is the interval to requeue expired, delayed and retried jobs, default 1s, negative to disable.
+	MaintainInterval time.Duration
+	// Middlewares wrap the handler, the first one is the outermost.
+	Middlewares []Middleware
+	// OnError is called with the errors of the worker itself, e.g. dequeue and ack failures.
+	OnError func(err error)
+}
+
+// Worker runs handlers over a reliable queue with blocking dequeue.
+type Worker struct {
+	queue   *redis.ReliableQueue
+	handler Handler
+	opts    Options
+}
+
+// NewWorker returns a Worker processing the jobs of q with handler, opts may be nil.
+func NewWorker(q *redis.ReliableQueue, handler Handler, opts *Options) *Worker {
+	w := &Worker{queue: q}
+	if opts != nil {
+		w.opts = *opts
+	}
+
+	if w.opts.Concurrency <= 0 {
+		w.opts.Concurrency = 1
+	}
+	if w.opts.Consumer == "" {
+		hostname, _ := os.Hostname()
+		w.opts.Consumer = hostname + "-" + strconv.Itoa(os.Getpid())
+	}
+	if w.opts.PollTimeout <= 0 {
+		w.opts.PollTimeout = time.Second
+	}
+	if w.opts.MaintainInterval == 0 {
+		w.opts.MaintainInterval = time.Second
+	}
+
+	// 按顺序包装中间件，第一个中间件在最外层，panic 恢复在最内层以便中间件感知到错误
+	w.handler = recoverHandler(handler)
+	for i := len(w.opts.Middlewares) - 1; i >= 0; i-- {
+		w.handler = w.opts.Middlewares[i](w.handler)
+	}
+
+	return w
+}
+
+// Run processes jobs until ctx is done, then waits for the running handlers to finish.
+// The handlers are not cancelled by ctx, they are only limited by JobTimeout.
+func (w *Worker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	if w.opts.MaintainInterval > 0 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			w.queue.RunReaper(ctx, w.opts.MaintainInterval)
+		}()
+		go func() {
+			defer wg.Done()
+			redis.RunDelayedMover(ctx, w.queue.Pool(), w.queue.Name(), w.opts.MaintainInterval)
+		}()
+	}
+
+	for i := 0; i < w.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func(consumer string) {
+			defer wg.Done()
+			w.consume(ctx, consumer)
+		}(w.opts.Consumer + "-" + strconv.Itoa(i))
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (w *Worker) consume(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		job, err := w.queue.BDeQueue(consumer, w.opts.PollTimeout)
+		if errors.Is(err, redis.ErrQueueEmpty) {
+			continue
+		}
+		if err != nil {
+			w.onError(err)
+
+			// 出错时等待一个轮询周期，避免 redis 不可用时空转
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.opts.PollTimeout):
+			}
+
+			continue
+		}
+
+		w.process(context.WithoutCancel(ctx), job)
+	}
+}
+
+func (w *Worker) process(ctx context.Context, job *redis.Job) {
+	if w.opts.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.opts.JobTimeout)
+		defer cancel()
+	}
+
+	if err := w.handler(ctx, job); err != nil {
+		if _, err = w.queue.Retry(job, w.opts.RetryPolicy); err != nil {
+			w.onError(fmt.Errorf("retry job %s: %w", job.Key, err))
+		}
+
+		return
+	}
+
+	if err := w.queue.Ack(job); err != nil {
+		w.onError(fmt.Errorf("ack job %s: %w", job.Key, err))
+	}
+}
+
+func (w *Worker) onError(err error) {
+	if w.opts.OnError != nil {
+		w.opts.OnError(err)
+	}
+}
+
+// recoverHandler converts a panic of handler into an error.
+func recoverHandler(handler Handler) Handler {
+	return func(ctx context.Context, job *redis.Job) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("queue: handler panic: %v\n%s", r, debug.Stack())
+			}
+		}()
+
+		return handler(ctx, job)
+	}
+}
+
+// Logging logs the result and the duration of every job.
+func Logging(logf func(format string, args ...any)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, job *redis.Job) error {
+			start := time.Now()
+			err := next(ctx, job)
+			if err != nil {
+				logf("queue %s job %s attempts %d failed in %s: %v", job.Queue, job.Key, job.Attempts, time.Since(start), err)
+			} else {
+				logf("queue %s job %s done in %s", job.Queue, job.Key, time.Since(start))
+			}
+
+			return err
+		}
+	}
+}
+
+// Metrics reports the duration and the error of every job, e.g. to a latency histogram.
+func Metrics(observe func(queue string, duration time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, job *redis.Job) error {
+			start := time.Now()
+			err := next(ctx, job)
+			observe(job.Queue, time.Since(start), err)
+
+			return err
+		}
+	}
+}
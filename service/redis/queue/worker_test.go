@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dapings/kit/service/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWorker_Middlewares(t *testing.T) {
+	var calls []string
+	middleware := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, job *redis.Job) error {
+				calls = append(calls, name)
+				return next(ctx, job)
+			}
+		}
+	}
+
+	var gotErr error
+	observe := func(next Handler) Handler {
+		return func(ctx context.Context, job *redis.Job) error {
+			gotErr = next(ctx, job)
+			return gotErr
+		}
+	}
+
+	handler := func(ctx context.Context, job *redis.Job) error {
+		calls = append(calls, "handler")
+		panic("boom")
+	}
+	w := NewWorker(nil, handler, &Options{Middlewares: []Middleware{middleware("first"), observe, middleware("second")}})
+
+	err := w.handler(context.Background(), &redis.Job{Key: "k"})
+	assert.Error(t, err)
+	assert.Equal(t, err, gotErr)
+	assert.Equal(t, []string{"first", "second", "handler"}, calls)
+}
+
+func TestRecoverHandler(t *testing.T) {
+	wantErr := errors.New("failed")
+	handler := recoverHandler(func(ctx context.Context, job *redis.Job) error {
+		return wantErr
+	})
+	assert.Equal(t, wantErr, handler(context.Background(), &redis.Job{}))
+
+	handler = recoverHandler(func(ctx context.Context, job *redis.Job) error {
+		panic("boom")
+	})
+	assert.ErrorContains(t, handler(context.Background(), &redis.Job{}), "handler panic: boom")
+}
+
+func TestWorker_Run(t *testing.T) {
+	s := miniredis.RunT(t)
+	pool, err := redis.NewRedisPool([]redis.Server{{Host: s.Host(), Port: s.Port()}})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = pool.Close() })
+
+	q := redis.NewReliableQueue(pool, "queue", time.Minute)
+	for _, key := range []string{"ok", "fail", "slow"} {
+		assert.NoError(t, q.EnQueue(key, []byte(key)))
+	}
+
+	var (
+		mu      sync.Mutex
+		handled []string
+		errs    []error
+	)
+	handler := func(ctx context.Context, job *redis.Job) error {
+		defer func() {
+			mu.Lock()
+			handled = append(handled, job.Key)
+			mu.Unlock()
+		}()
+
+		switch job.Key {
+		case "fail":
+			return errors.New("failed")
+		case "slow":
+			// 超过 JobTimeout 后 ctx 被取消
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+	w := NewWorker(q, handler, &Options{
+		Consumer:         "worker",
+		PollTimeout:      50 * time.Millisecond,
+		JobTimeout:       50 * time.Millisecond,
+		RetryPolicy:      &redis.RetryPolicy{MaxAttempts: 1, Backoff: redis.ConstantBackoff(time.Hour)},
+		MaintainInterval: -1,
+		OnError: func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// 队列为空时阻塞出队超时，继续轮询而不报错
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case err = <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("worker not stopped")
+	}
+
+	assert.Equal(t, []string{"ok", "fail", "slow"}, handled)
+	assert.Empty(t, errs)
+	assert.False(t, s.Exists("queue"))
+	assert.False(t, s.Exists("queue_processing_worker-0"))
+
+	// 成功的任务被确认，失败和超时的任务等待重试
+	retries, err := s.ZMembers("queue_retry")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"fail", "slow"}, retries)
+	for key, want := range map[string]int{"ok": 0, "fail": 1, "slow": 1} {
+		attempts, err := redis.Attempts(pool, "queue", key)
+		assert.NoError(t, err)
+		assert.Equal(t, want, attempts, key)
+	}
+}
@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	now := time.Now().Truncate(time.Millisecond)
+	s.SetTime(now)
+
+	policy := &RetryPolicy{MaxAttempts: 1, Backoff: ExponentialBackoff(time.Minute, time.Minute)}
+	dead, err := Retry(p, "queue", "a", []byte("1"), policy)
+	assert.NoError(t, err)
+	assert.False(t, dead)
+	attempts, err := Attempts(p, "queue", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+
+	score, err := s.ZScore("queue"+retryQueueSuffix, "a")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, score, float64(now.Add(30*time.Second).UnixMilli()))
+	assert.LessOrEqual(t, score, float64(now.Add(time.Minute).UnixMilli()))
+
+	n, err := PromoteRetries(p, "queue", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	s.SetTime(now.Add(time.Minute))
+	n, err = PromoteRetries(p, "queue", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	key, data := DeQueue(p, "queue")
+	assert.Equal(t, "a", key)
+	assert.Equal(t, []byte("1"), data)
+
+	// 超过最大次数后进入死信队列，重新入队后失败次数清零
+	dead, err = Retry(p, "queue", "a", []byte("2"), policy)
+	assert.NoError(t, err)
+	assert.True(t, dead)
+	length, err := DeadLetterLen(p, "queue")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+	attempts, err = Attempts(p, "queue", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, attempts)
+
+	n, err = Requeue(p, "queue", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	key, data = DeQueue(p, "queue")
+	assert.Equal(t, "a", key)
+	assert.Equal(t, []byte("2"), data)
+}
+
+func TestPromoteAllRetries(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	for _, key := range []string{"a", "b"} {
+		_, err := Retry(p, "queue", key, []byte(key), &RetryPolicy{MaxAttempts: 1, Backoff: ConstantBackoff(time.Hour)})
+		assert.NoError(t, err)
+	}
+
+	n, err := PromoteAllRetries(p, "queue")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	keys, err := s.List("queue")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, keys)
+	assert.False(t, s.Exists("queue"+retryQueueSuffix))
+}
+
+func TestRetryAll(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	// 已废弃的 RetryAll 不移动任何任务
+	_, err := Retry(p, "queue", "a", nil, &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(time.Hour)})
+	assert.NoError(t, err)
+	assert.False(t, RetryAll(p, "queue"))
+	assert.False(t, s.Exists("queue"))
+
+	assert.False(t, RetryAll(p, "key"))
+	assert.NoError(t, s.Set("key", "1"))
+	assert.True(t, RetryAll(p, "key"))
+}
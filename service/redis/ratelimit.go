@@ -0,0 +1,227 @@
+package redis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 限流脚本均使用 redis TIME 作为时钟，多个实例共享同一时钟；返回 {是否允许, 剩余配额, 重试等待毫秒数}，
+// 重试等待为 -1 表示请求数超过了容量，永远无法满足。
+const (
+	// ScriptTokenBucket takes ARGV[3] tokens from the bucket refilled with ARGV[1] tokens per millisecond up to ARGV[2].
+	// KEYS: bucket hash
+	// ARGV: rate, burst, n
+	ScriptTokenBucket = `
+		local t = redis.call("TIME")
+		local now = t[1] * 1000 + math.floor(t[2] / 1000)
+		local rate = tonumber(ARGV[1])
+		local burst = tonumber(ARGV[2])
+		local n = tonumber(ARGV[3])
+
+		local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+		local tokens = tonumber(state[1]) or burst
+		local ts = tonumber(state[2]) or now
+		tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+		local allowed, retry = 0, -1
+		if tokens >= n then
+			tokens = tokens - n
+			allowed, retry = 1, 0
+		elseif n <= burst then
+			retry = math.ceil((n - tokens) / rate)
+		end
+
+		redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+		redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+		return {allowed, math.floor(tokens), retry}
+	`
+	// ScriptFixedWindow counts ARGV[2] requests in the window of ARGV[3] milliseconds started by the first request,
+	// the rejected requests are not counted.
+	// KEYS: counter
+	// ARGV: limit, n, window
+	ScriptFixedWindow = `
+		local limit = tonumber(ARGV[1])
+		local n = tonumber(ARGV[2])
+		local count = redis.call("INCRBY", KEYS[1], n)
+		local ttl = redis.call("PTTL", KEYS[1])
+		if ttl < 0 then
+			redis.call("PEXPIRE", KEYS[1], ARGV[3])
+			ttl = tonumber(ARGV[3])
+		end
+		if count > limit then
+			redis.call("DECRBY", KEYS[1], n)
+			if n > limit then
+				ttl = -1
+			end
+			return {0, math.max(0, limit - count + n), ttl}
+		end
+		return {1, limit - count, 0}
+	`
+	// ScriptSlidingLog logs ARGV[3] requests if the requests logged in the last ARGV[2] milliseconds are fewer than ARGV[1].
+	// KEYS: log zset, sequence
+	// ARGV: limit, window, n
+	ScriptSlidingLog = `
+		local t = redis.call("TIME")
+		local now = t[1] * 1000 + math.floor(t[2] / 1000)
+		local limit = tonumber(ARGV[1])
+		local window = tonumber(ARGV[2])
+		local n = tonumber(ARGV[3])
+
+		redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+		local count = redis.call("ZCARD", KEYS[1])
+		if count + n > limit then
+			local retry = -1
+			if n <= limit then
+				local i = count + n - limit - 1
+				local oldest = redis.call("ZRANGE", KEYS[1], i, i, "WITHSCORES")
+				retry = math.max(1, tonumber(oldest[2]) + window - now)
+			end
+			return {0, math.max(0, limit - count), retry}
+		end
+
+		local seq = redis.call("INCRBY", KEYS[2], n)
+		for i = 1, n do
+			redis.call("ZADD", KEYS[1], now, seq - n + i)
+		end
+		redis.call("PEXPIRE", KEYS[1], window)
+		redis.call("PEXPIRE", KEYS[2], window)
+		return {1, limit - count - n, 0}
+	`
+)
+
+var (
+	scriptTokenBucket = RegisterScript("ratelimit.token_bucket", 1, ScriptTokenBucket)
+	scriptFixedWindow = RegisterScript("ratelimit.fixed_window", 1, ScriptFixedWindow)
+	scriptSlidingLog  = RegisterScript("ratelimit.sliding_log", 2, ScriptSlidingLog)
+)
+
+// RateLimitResult is the decision of a rate limiter.
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of requests still allowed now.
+	Remaining int64
+	// RetryAfter is the time to wait before the rejected requests are allowed, it is 0 if allowed,
+	// and negative if the requests exceed the capacity of the limiter and are never allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimiter limits the requests of a key across instances sharing the redis.
+type RateLimiter interface {
+	// AllowN reports whether n requests of the key are allowed now, the allowed requests are counted.
+	AllowN(key string, n int64) (*RateLimitResult, error)
+}
+
+var errInvalidLimit = errors.New("redis: rate limit must be positive")
+
+var (
+	_ RateLimiter = &TokenBucketLimiter{}
+	_ RateLimiter = &FixedWindowLimiter{}
+	_ RateLimiter = &SlidingLogLimiter{}
+)
+
+// TokenBucketLimiter allows bursts of up to burst requests and refills rate tokens per second.
+type TokenBucketLimiter struct {
+	pool  *Pool
+	rate  float64
+	burst int64
+}
+
+func NewTokenBucketLimiter(pool *Pool, rate float64, burst int64) (*TokenBucketLimiter, error) {
+	if rate <= 0 || burst <= 0 {
+		return nil, errInvalidLimit
+	}
+
+	return &TokenBucketLimiter{pool: pool, rate: rate, burst: burst}, nil
+}
+
+func (l *TokenBucketLimiter) Allow(key string) (*RateLimitResult, error) {
+	return l.AllowN(key, 1)
+}
+
+func (l *TokenBucketLimiter) AllowN(key string, n int64) (*RateLimitResult, error) {
+	return rateLimitResult(l.pool.EvalScript(scriptTokenBucket, key, l.rate/1000, l.burst, n))
+}
+
+// FixedWindowLimiter allows limit requests per window, the window starts at the first request of the key,
+// it is the atomic form of Incr and Expire.
+type FixedWindowLimiter struct {
+	pool   *Pool
+	limit  int64
+	window time.Duration
+}
+
+func NewFixedWindowLimiter(pool *Pool, limit int64, window time.Duration) (*FixedWindowLimiter, error) {
+	if limit <= 0 || window < time.Millisecond {
+		return nil, errInvalidLimit
+	}
+
+	return &FixedWindowLimiter{pool: pool, limit: limit, window: window}, nil
+}
+
+func (l *FixedWindowLimiter) Allow(key string) (*RateLimitResult, error) {
+	return l.AllowN(key, 1)
+}
+
+func (l *FixedWindowLimiter) AllowN(key string, n int64) (*RateLimitResult, error) {
+	return rateLimitResult(l.pool.EvalScript(scriptFixedWindow, key, l.limit, n, l.window.Milliseconds()))
+}
+
+// Reset clears the counter of the key.
+func (l *FixedWindowLimiter) Reset(key string) error {
+	return l.pool.Del(key)
+}
+
+// slidingLogSeqSuffix is the suffix of the sequence key generating the members of a sliding log.
+const slidingLogSeqSuffix = "_sliding_log_seq"
+
+// SlidingLogLimiter allows limit requests in any window, it logs every allowed request in a sorted set,
+// so it is exact but costs memory proportional to limit per key.
+type SlidingLogLimiter struct {
+	pool   *Pool
+	limit  int64
+	window time.Duration
+}
+
+func NewSlidingLogLimiter(pool *Pool, limit int64, window time.Duration) (*SlidingLogLimiter, error) {
+	if limit <= 0 || window < time.Millisecond {
+		return nil, errInvalidLimit
+	}
+
+	return &SlidingLogLimiter{pool: pool, limit: limit, window: window}, nil
+}
+
+func (l *SlidingLogLimiter) Allow(key string) (*RateLimitResult, error) {
+	return l.AllowN(key, 1)
+}
+
+func (l *SlidingLogLimiter) AllowN(key string, n int64) (*RateLimitResult, error) {
+	return rateLimitResult(l.pool.EvalScript(scriptSlidingLog, key, key+slidingLogSeqSuffix, l.limit, l.window.Milliseconds(), n))
+}
+
+// Reset clears the log of the key.
+func (l *SlidingLogLimiter) Reset(key string) error {
+	if err := l.pool.Del(key); err != nil {
+		return err
+	}
+
+	return l.pool.Del(key + slidingLogSeqSuffix)
+}
+
+// rateLimitResult converts a reply of the rate limit scripts.
+func rateLimitResult(reply any, err error) (*RateLimitResult, error) {
+	values, err := redis.Int64s(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, WrongAnswer
+	}
+
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
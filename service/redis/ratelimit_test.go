@@ -0,0 +1,164 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitResult(t *testing.T) {
+	testCases := []struct {
+		name  string
+		reply any
+		want  *RateLimitResult
+	}{
+		{
+			name:  "allowed",
+			reply: []any{int64(1), int64(9), int64(0)},
+			want:  &RateLimitResult{Allowed: true, Remaining: 9},
+		},
+		{
+			name:  "rejected",
+			reply: []any{int64(0), int64(0), int64(250)},
+			want:  &RateLimitResult{RetryAfter: 250 * time.Millisecond},
+		},
+		{
+			name:  "exceeds capacity",
+			reply: []any{int64(0), int64(3), int64(-1)},
+			want:  &RateLimitResult{Remaining: 3, RetryAfter: -time.Millisecond},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := rateLimitResult(tc.reply, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := rateLimitResult([]any{int64(1)}, nil)
+	assert.Equal(t, WrongAnswer, err)
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	_, err := NewTokenBucketLimiter(nil, 0, 10)
+	assert.Equal(t, errInvalidLimit, err)
+	_, err = NewTokenBucketLimiter(nil, 10, 0)
+	assert.Equal(t, errInvalidLimit, err)
+	_, err = NewFixedWindowLimiter(nil, 10, time.Microsecond)
+	assert.Equal(t, errInvalidLimit, err)
+	_, err = NewFixedWindowLimiter(nil, 0, time.Second)
+	assert.Equal(t, errInvalidLimit, err)
+	_, err = NewSlidingLogLimiter(nil, 0, time.Second)
+	assert.Equal(t, errInvalidLimit, err)
+	_, err = NewSlidingLogLimiter(nil, 10, time.Microsecond)
+	assert.Equal(t, errInvalidLimit, err)
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	now := time.Now().Truncate(time.Millisecond)
+	s.SetTime(now)
+
+	l, err := NewTokenBucketLimiter(p, 10, 5)
+	assert.NoError(t, err)
+
+	got, err := l.AllowN("api", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{Allowed: true}, got)
+
+	// 令牌用完后按速率补充，每 100ms 一个
+	got, err = l.Allow("api")
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{RetryAfter: 100 * time.Millisecond}, got)
+
+	s.SetTime(now.Add(250 * time.Millisecond))
+	got, err = l.Allow("api")
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{Allowed: true, Remaining: 1}, got)
+
+	got, err = l.AllowN("api", 6)
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{Remaining: 1, RetryAfter: -time.Millisecond}, got)
+
+	// 不同 key 互不影响
+	got, err = l.Allow("other")
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{Allowed: true, Remaining: 4}, got)
+}
+
+func TestFixedWindowLimiter(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	l, err := NewFixedWindowLimiter(p, 3, time.Second)
+	assert.NoError(t, err)
+
+	for remaining := int64(2); remaining >= 0; remaining-- {
+		got, err := l.Allow("api")
+		assert.NoError(t, err)
+		assert.Equal(t, &RateLimitResult{Allowed: true, Remaining: remaining}, got)
+	}
+
+	// 拒绝的请求不计数，等待窗口结束
+	got, err := l.Allow("api")
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{RetryAfter: time.Second}, got)
+	s.CheckGet(t, "api", "3")
+
+	s.FastForward(time.Second)
+	got, err = l.AllowN("api", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{Allowed: true, Remaining: 1}, got)
+
+	got, err = l.AllowN("api", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{Remaining: 1, RetryAfter: -time.Millisecond}, got)
+
+	assert.NoError(t, l.Reset("api"))
+	got, err = l.AllowN("api", 3)
+	assert.NoError(t, err)
+	assert.True(t, got.Allowed)
+}
+
+func TestSlidingLogLimiter(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	now := time.Now().Truncate(time.Millisecond)
+	s.SetTime(now)
+
+	l, err := NewSlidingLogLimiter(p, 2, time.Second)
+	assert.NoError(t, err)
+
+	got, err := l.Allow("api")
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{Allowed: true, Remaining: 1}, got)
+
+	s.SetTime(now.Add(400 * time.Millisecond))
+	got, err = l.Allow("api")
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{Allowed: true}, got)
+
+	// 等待最早的请求移出窗口
+	s.SetTime(now.Add(500 * time.Millisecond))
+	got, err = l.Allow("api")
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{RetryAfter: 500 * time.Millisecond}, got)
+
+	s.SetTime(now.Add(time.Second))
+	got, err = l.Allow("api")
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{Allowed: true}, got)
+
+	got, err = l.AllowN("api", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, &RateLimitResult{RetryAfter: -time.Millisecond}, got)
+
+	// 序号使用限流器自己的 key，不与优先级队列冲突
+	assert.True(t, s.Exists("api"+slidingLogSeqSuffix))
+	assert.False(t, s.Exists("api"+sequenceSuffix))
+
+	assert.NoError(t, l.Reset("api"))
+	assert.False(t, s.Exists("api"))
+	assert.False(t, s.Exists("api"+slidingLogSeqSuffix))
+}
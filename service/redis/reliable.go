@@ -0,0 +1,473 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	processingSuffix = "_processing"
+	deadlineSuffix   = "_deadline"
+	consumersSuffix  = "_consumers"
+
+	// consumerSeparator separates the consumer and the key in the members of the deadline sorted set.
+	consumerSeparator = "|"
+
+	// consumerRegisterMargin extends the registration of a consumer beyond its blocking dequeue,
+	// in case the BLMOVE reaches the server late.
+	consumerRegisterMargin = time.Minute
+)
+
+// 可靠队列在原有队列（list 存 key，<queue>_htable 存数据）的基础上，
+// 出队时将 key 原子地移动到消费者的处理中列表 <queue>_processing_<consumer>，数据移动到 <queue>_processing_<consumer>_htable，
+// 并在 <queue>_deadline 中记录可见性超时时间，消费者确认后才删除；超时未确认的由 Reap 重新入队。
+// 消费者记录在有序集合 <queue>_consumers 中，score 为消费者可能向处理中列表移动 key 的截止时间（毫秒），
+// 阻塞出队在移动 key 后、认领数据前崩溃时，由 Reap 为其补充可见性超时时间；
+// 处理中列表为空且已过截止时间的消费者由 Reap 删除，避免重启后的消费者名称不断累积。
+const (
+	// ScriptReliableDeQueue moves the first key of the queue to the processing list of the consumer,
+	// it returns the key, the data and the failed times.
+	// KEYS: queue, queue htable, processing list, processing htable, deadline zset, attempts hash, consumers zset
+	// ARGV: consumer, deadline in milliseconds
+	ScriptReliableDeQueue = `
+		local key = redis.call("LPOP", KEYS[1])
+		if not key then
+			return false
+		end
+		redis.call("ZADD", KEYS[7], ARGV[2], ARGV[1])
+		redis.call("RPUSH", KEYS[3], key)
+		local data = redis.call("HGET", KEYS[2], key)
+		if data then
+			redis.call("HDEL", KEYS[2], key)
+			redis.call("HSET", KEYS[4], key, data)
+		end
+		redis.call("ZADD", KEYS[5], ARGV[2], ARGV[1] .. "|" .. key)
+		return {key, data, redis.call("HGET", KEYS[6], key)}
+	`
+	// ScriptReliableAck removes the key from the processing list of the consumer and clears its failed times.
+	// KEYS: processing list, processing htable, deadline zset, attempts hash
+	// ARGV: consumer, key
+	ScriptReliableAck = `
+		redis.call("ZREM", KEYS[3], ARGV[1] .. "|" .. ARGV[2])
+		redis.call("HDEL", KEYS[2], ARGV[2])
+		local n = redis.call("LREM", KEYS[1], 1, ARGV[2])
+		if n > 0 then
+			redis.call("HDEL", KEYS[4], ARGV[2])
+		end
+		return n
+	`
+	// ScriptReliableRetry removes the key from the processing list of the consumer and records its failure,
+	// then schedules it to be retried at ARGV[4], or moves it to the dead letter list like ScriptRetry.
+	// It returns -1 if the key is not in the processing list, 1 if it is dead lettered, otherwise 0.
+	// KEYS: processing list, processing htable, deadline zset, attempts hash, retry zset, retry htable, dead list, dead htable
+	// ARGV: consumer, key, data, retry time in milliseconds, max attempts
+	ScriptReliableRetry = `
+		redis.call("ZREM", KEYS[3], ARGV[1] .. "|" .. ARGV[2])
+		redis.call("HDEL", KEYS[2], ARGV[2])
+		if redis.call("LREM", KEYS[1], 1, ARGV[2]) == 0 then
+			return -1
+		end
+		local attempts = redis.call("HINCRBY", KEYS[4], ARGV[2], 1)
+		if tonumber(ARGV[4]) < 0 or attempts > tonumber(ARGV[5]) then
+			redis.call("HDEL", KEYS[4], ARGV[2])
+			if redis.call("HSET", KEYS[8], ARGV[2], ARGV[3]) == 1 then
+				redis.call("RPUSH", KEYS[7], ARGV[2])
+			end
+			return 1
+		end
+		redis.call("ZADD", KEYS[5], ARGV[4], ARGV[2])
+		redis.call("HSET", KEYS[6], ARGV[2], ARGV[3])
+		return 0
+	`
+	// ScriptReliableNack moves the key from the processing list of the consumer back to the queue,
+	// the key is dropped if it has been enqueued again with newer data.
+	// KEYS: queue, queue htable, processing list, processing htable, deadline zset
+	// ARGV: consumer, key
+	ScriptReliableNack = `
+		redis.call("ZREM", KEYS[5], ARGV[1] .. "|" .. ARGV[2])
+		if redis.call("LREM", KEYS[3], 1, ARGV[2]) == 0 then
+			return 0
+		end
+		local data = redis.call("HGET", KEYS[4], ARGV[2])
+		redis.call("HDEL", KEYS[4], ARGV[2])
+		if redis.call("HEXISTS", KEYS[2], ARGV[2]) == 0 then
+			if data then
+				redis.call("HSET", KEYS[2], ARGV[2], data)
+			end
+			redis.call("RPUSH", KEYS[1], ARGV[2])
+		end
+		return 1
+	`
+	// ScriptReliableClaim claims the key moved to the processing list of the consumer by BLMOVE,
+	// it returns the key, the data and the failed times.
+	// KEYS: queue htable, processing htable, deadline zset, attempts hash
+	// ARGV: consumer, deadline in milliseconds, key
+	ScriptReliableClaim = `
+		local data = redis.call("HGET", KEYS[1], ARGV[3])
+		if data then
+			redis.call("HDEL", KEYS[1], ARGV[3])
+			redis.call("HSET", KEYS[2], ARGV[3], data)
+		end
+		redis.call("ZADD", KEYS[3], ARGV[2], ARGV[1] .. "|" .. ARGV[3])
+		return {ARGV[3], data, redis.call("HGET", KEYS[4], ARGV[3])}
+	`
+	// ScriptReliableReap moves the keys whose visibility timeout expired back to the queue,
+	// the keys in the processing lists without visibility timeout are given one,
+	// and the consumers whose processing lists are empty are removed after their registration expires.
+	// It returns the number of the expired keys handled.
+	// KEYS: queue, queue htable, deadline zset, consumers zset, then the processing list and htable of each consumer
+	// ARGV: now in milliseconds, limit, visibility timeout in milliseconds, then the consumers
+	ScriptReliableReap = `
+		local now = tonumber(ARGV[1])
+		local lists = {}
+		for i = 4, #ARGV do
+			local consumer = ARGV[i]
+			local processing, htable = KEYS[2 * i - 3], KEYS[2 * i - 2]
+			lists[consumer] = {processing, htable}
+			local keys = redis.call("LRANGE", processing, 0, -1)
+			if #keys == 0 then
+				local score = redis.call("ZSCORE", KEYS[4], consumer)
+				if score and tonumber(score) < now then
+					redis.call("ZREM", KEYS[4], consumer)
+				end
+			end
+			for _, key in ipairs(keys) do
+				local member = consumer .. "|" .. key
+				if not redis.call("ZSCORE", KEYS[3], member) then
+					redis.call("ZADD", KEYS[3], now + ARGV[3], member)
+				end
+			end
+		end
+		local n = 0
+		local expired = redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", now, "LIMIT", 0, ARGV[2])
+		for _, member in ipairs(expired) do
+			local sep = string.find(member, "|", 1, true)
+			local key = string.sub(member, sep + 1)
+			local consumer = string.sub(member, 1, sep - 1)
+			local list = lists[consumer]
+			if list then
+				n = n + 1
+				redis.call("ZREM", KEYS[3], member)
+				if redis.call("LREM", list[1], 1, key) > 0 then
+					-- 未认领的 key 的数据仍在队列的 htable 中，直接重新入队
+					local data = redis.call("HGET", list[2], key)
+					redis.call("HDEL", list[2], key)
+					if not data then
+						redis.call("RPUSH", KEYS[1], key)
+					elseif redis.call("HEXISTS", KEYS[2], key) == 0 then
+						redis.call("HSET", KEYS[2], key, data)
+						redis.call("RPUSH", KEYS[1], key)
+					end
+				end
+			elseif not redis.call("ZSCORE", KEYS[4], consumer) then
+				-- 未注册的消费者的处理中列表必然为空，直接删除残留的超时记录；
+				-- 读取消费者列表后新注册的消费者留给下次处理
+				n = n + 1
+				redis.call("ZREM", KEYS[3], member)
+			end
+		end
+		return n
+	`
+)
+
+var (
+	scriptReliableDeQueue = RegisterScript("queue.reliable_dequeue", 7, ScriptReliableDeQueue)
+	scriptReliableClaim   = RegisterScript("queue.reliable_claim", 4, ScriptReliableClaim)
+	scriptReliableAck     = RegisterScript("queue.reliable_ack", 4, ScriptReliableAck)
+	scriptReliableNack    = RegisterScript("queue.reliable_nack", 5, ScriptReliableNack)
+	scriptReliableRetry   = RegisterScript("queue.reliable_retry", 8, ScriptReliableRetry)
+	// the processing lists of the consumers follow the 4 keys of ScriptReliableReap
+	scriptReliableReap = RegisterScript("queue.reliable_reap", 4, ScriptReliableReap)
+)
+
+var (
+	// ErrQueueEmpty is returned when dequeuing from an empty queue.
+	ErrQueueEmpty = errors.New("redis: queue is empty")
+	// ErrJobNotFound is returned when acknowledging a job which is not in the processing list of the consumer.
+	ErrJobNotFound = errors.New("redis: job not found in processing list")
+)
+
+// Job is a dequeued item of a queue.
+type Job struct {
+	Queue    string
+	Consumer string
+	Key      string
+	Data     []byte
+	// Attempts is the failed times of the job recorded by Retry.
+	Attempts int
+}
+
+// ReliableQueue is a queue compatible with EnQueue/DeQueue, whose dequeued jobs are kept in the processing list of the consumer
+// until they are acknowledged, the jobs are requeued if the consumer does not acknowledge them within the visibility timeout.
+type ReliableQueue struct {
+	pool              *Pool
+	name              string
+	visibilityTimeout time.Duration
+}
+
+func NewReliableQueue(pool *Pool, queueName string, visibilityTimeout time.Duration) *ReliableQueue {
+	return &ReliableQueue{pool: pool, name: queueName, visibilityTimeout: visibilityTimeout}
+}
+
+func (q *ReliableQueue) Name() string {
+	return q.name
+}
+
+func (q *ReliableQueue) Pool() *Pool {
+	return q.pool
+}
+
+func (q *ReliableQueue) processingKey(consumer string) string {
+	return q.name + processingSuffix + "_" + consumer
+}
+
+func (q *ReliableQueue) deadlineKey() string {
+	return q.name + deadlineSuffix
+}
+
+// EnQueue adds a job to the queue, the data of a key already in the queue is merged, see EnQueue.
+func (q *ReliableQueue) EnQueue(key string, data []byte) error {
+	return EnQueue(q.pool, q.name, key, data)
+}
+
+// DeQueue moves the first job of the queue to the processing list of consumer,
+// ErrQueueEmpty is returned if the queue is empty.
+func (q *ReliableQueue) DeQueue(consumer string) (*Job, error) {
+	if err := checkConsumer(consumer); err != nil {
+		return nil, err
+	}
+
+	now, err := q.pool.ServerTime()
+	if err != nil {
+		return nil, err
+	}
+
+	processing := q.processingKey(consumer)
+	deadline := now.Add(q.visibilityTimeout).UnixMilli()
+	result, err := q.pool.EvalScript(scriptReliableDeQueue, q.name, q.name+hTable, processing, processing+hTable,
+		q.deadlineKey(), q.name+attemptsSuffix, q.name+consumersSuffix, consumer, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, ErrQueueEmpty
+	}
+
+	return q.parseJob(consumer, result)
+}
+
+// BDeQueue is the blocking version of DeQueue, it waits at most timeout for a job,
+// ErrQueueEmpty is returned if no job is available, BLMOVE requires redis 6.2 or later.
+// The timeout must be positive, BLMOVE would block forever with 0.
+func (q *ReliableQueue) BDeQueue(consumer string, timeout time.Duration) (*Job, error) {
+	if err := checkConsumer(consumer); err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("redis: invalid blocking timeout: %s", timeout)
+	}
+
+	// 每次阻塞前注册消费者，截止时间覆盖阻塞时间，保证 Reap 不会删除正在阻塞出队的消费者
+	now, err := q.pool.ServerTime()
+	if err != nil {
+		return nil, err
+	}
+	registered := now.Add(timeout + consumerRegisterMargin).UnixMilli()
+	if _, err = q.pool.Do("ZADD", q.name+consumersSuffix, registered, consumer); err != nil {
+		return nil, err
+	}
+
+	// BLMOVE 不能在 Lua 脚本中执行，先阻塞地将 key 移动到处理中列表，再通过脚本认领数据
+	processing := q.processingKey(consumer)
+	key, err := redis.String(q.pool.DoWithTimeout(timeout+time.Second,
+		"BLMOVE", q.name, processing, "LEFT", "RIGHT", timeout.Seconds()))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, ErrQueueEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if now, err = q.pool.ServerTime(); err != nil {
+		return nil, err
+	}
+
+	deadline := now.Add(q.visibilityTimeout).UnixMilli()
+	result, err := q.pool.EvalScript(scriptReliableClaim, q.name+hTable, processing+hTable,
+		q.deadlineKey(), q.name+attemptsSuffix, consumer, deadline, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.parseJob(consumer, result)
+}
+
+func (q *ReliableQueue) parseJob(consumer string, result any) (*Job, error) {
+	elems, err := redis.Values(result, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		return nil, WrongAnswer
+	}
+
+	key, err := redis.String(elems[0], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{Queue: q.name, Consumer: consumer, Key: key}
+	if len(elems) > 1 && elems[1] != nil {
+		if job.Data, err = redis.Bytes(elems[1], nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(elems) > 2 && elems[2] != nil {
+		if job.Attempts, err = redis.Int(elems[2], nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return job, nil
+}
+
+// Ack acknowledges the job is done and removes it from the processing list,
+// ErrJobNotFound is returned if it is not in the processing list, e.g. it has been requeued by Reap.
+func (q *ReliableQueue) Ack(job *Job) error {
+	processing := q.processingKey(job.Consumer)
+	n, err := redis.Int64(q.pool.EvalScript(scriptReliableAck,
+		processing, processing+hTable, q.deadlineKey(), q.name+attemptsSuffix, job.Consumer, job.Key))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// Nack moves the job from the processing list back to the queue so it is delivered again,
+// the job is dropped if the key has been enqueued again with newer data meanwhile.
+func (q *ReliableQueue) Nack(job *Job) error {
+	processing := q.processingKey(job.Consumer)
+	n, err := redis.Int64(q.pool.EvalScript(scriptReliableNack,
+		q.name, q.name+hTable, processing, processing+hTable, q.deadlineKey(), job.Consumer, job.Key))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// Retry removes the failed job from the processing list and schedules it with policy, see Retry,
+// both are done atomically so the job is never lost in between.
+func (q *ReliableQueue) Retry(job *Job, policy *RetryPolicy) (dead bool, err error) {
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
+
+	due, err := policy.retryTime(q.pool, job.Attempts+1)
+	if err != nil {
+		return false, err
+	}
+
+	processing := q.processingKey(job.Consumer)
+	retry := q.name + retryQueueSuffix
+	deadLetter := q.name + deadLetterSuffix
+	n, err := redis.Int64(q.pool.EvalScript(scriptReliableRetry,
+		processing, processing+hTable, q.deadlineKey(), q.name+attemptsSuffix, retry, retry+hTable, deadLetter, deadLetter+hTable,
+		job.Consumer, job.Key, job.Data, due, policy.MaxAttempts))
+	if err != nil {
+		return false, err
+	}
+	if n < 0 {
+		return false, ErrJobNotFound
+	}
+
+	return n == 1, nil
+}
+
+// Extend resets the visibility timeout of the job which needs more time to process.
+func (q *ReliableQueue) Extend(job *Job, timeout time.Duration) error {
+	now, err := q.pool.ServerTime()
+	if err != nil {
+		return err
+	}
+
+	n, err := redis.Int64(q.pool.Do("ZADD", q.deadlineKey(), "XX", "CH",
+		now.Add(timeout).UnixMilli(), job.Consumer+consumerSeparator+job.Key))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// Reap requeues at most limit jobs whose visibility timeout expired, it returns the number of expired jobs.
+// The consumers without jobs in process are unregistered after their last dequeue expires.
+func (q *ReliableQueue) Reap(limit int) (int, error) {
+	consumers, err := redis.Strings(q.pool.Do("ZRANGE", q.name+consumersSuffix, 0, -1))
+	if err != nil {
+		return 0, err
+	}
+
+	now, err := q.pool.ServerTime()
+	if err != nil {
+		return 0, err
+	}
+
+	keysAndArgs := make([]any, 0, 4+3*len(consumers)+3)
+	keysAndArgs = append(keysAndArgs, q.name, q.name+hTable, q.deadlineKey(), q.name+consumersSuffix)
+	for _, consumer := range consumers {
+		processing := q.processingKey(consumer)
+		keysAndArgs = append(keysAndArgs, processing, processing+hTable)
+	}
+	keysAndArgs = append(keysAndArgs, now.UnixMilli(), limit, q.visibilityTimeout.Milliseconds())
+	for _, consumer := range consumers {
+		keysAndArgs = append(keysAndArgs, consumer)
+	}
+
+	return redis.Int(evalScriptKeys(q.pool, scriptReliableReap, 4+2*len(consumers), keysAndArgs...))
+}
+
+// RunReaper calls Reap every interval until ctx is done.
+func (q *ReliableQueue) RunReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// 每次最多处理 100 个，处理满时立即继续
+		for {
+			n, err := q.Reap(100)
+			if err != nil || n < 100 || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+}
+
+func checkConsumer(consumer string) error {
+	if consumer == "" || strings.Contains(consumer, consumerSeparator) {
+		return fmt.Errorf("redis: invalid consumer name %q", consumer)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package redis
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReliableQueue_parseJob(t *testing.T) {
+	q := &ReliableQueue{name: "queue"}
+	testCases := []struct {
+		name   string
+		result any
+
+		wantJob *Job
+		wantErr error
+	}{
+		{
+			name:    "with data",
+			result:  []any{[]byte("k"), []byte("v")},
+			wantJob: &Job{Queue: "queue", Consumer: "c", Key: "k", Data: []byte("v")},
+		},
+		{
+			name:    "with attempts",
+			result:  []any{[]byte("k"), []byte("v"), []byte("2")},
+			wantJob: &Job{Queue: "queue", Consumer: "c", Key: "k", Data: []byte("v"), Attempts: 2},
+		},
+		{
+			name:    "without data",
+			result:  []any{[]byte("k"), nil},
+			wantJob: &Job{Queue: "queue", Consumer: "c", Key: "k"},
+		},
+		{
+			name:    "empty",
+			result:  []any{},
+			wantErr: WrongAnswer,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			job, err := q.parseJob("c", tc.result)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantJob, job)
+		})
+	}
+}
+
+func TestCheckConsumer(t *testing.T) {
+	assert.NoError(t, checkConsumer("worker-1"))
+	assert.Error(t, checkConsumer(""))
+	assert.Error(t, checkConsumer("a|b"))
+}
+
+func TestReliableQueue_Retry(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	now := time.Now().Truncate(time.Millisecond)
+	s.SetTime(now)
+
+	q := NewReliableQueue(p, "queue", time.Minute)
+	policy := &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(time.Second)}
+	assert.NoError(t, q.EnQueue("a", []byte("1")))
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		job, err := q.DeQueue("c")
+		assert.NoError(t, err)
+		assert.Equal(t, attempt-1, job.Attempts)
+
+		// 移出处理中列表、记录失败次数和调度重试在同一个脚本中完成
+		dead, err := q.Retry(job, policy)
+		assert.NoError(t, err)
+		assert.False(t, dead)
+		assert.False(t, s.Exists(q.processingKey("c")))
+		assert.False(t, s.Exists(q.deadlineKey()))
+		score, err := s.ZScore("queue"+retryQueueSuffix, "a")
+		assert.NoError(t, err)
+		assert.Equal(t, float64(now.Add(time.Second).UnixMilli()), score)
+		assert.Equal(t, "1", s.HGet("queue"+retryQueueSuffix+hTable, "a"))
+		assert.Equal(t, strconv.Itoa(attempt), s.HGet("queue"+attemptsSuffix, "a"))
+
+		n, err := PromoteAllRetries(p, "queue")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	}
+
+	// 超过最大次数后进入死信队列
+	job, err := q.DeQueue("c")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, job.Attempts)
+	dead, err := q.Retry(job, policy)
+	assert.NoError(t, err)
+	assert.True(t, dead)
+	assert.False(t, s.Exists(q.processingKey("c")))
+	assert.False(t, s.Exists("queue"+retryQueueSuffix))
+	assert.False(t, s.Exists("queue"+attemptsSuffix))
+	keys, err := s.List("queue" + deadLetterSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+	assert.Equal(t, "1", s.HGet("queue"+deadLetterSuffix+hTable, "a"))
+
+	// 已不在处理中列表的任务不会被重复调度
+	_, err = q.Retry(job, policy)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	assert.False(t, s.Exists("queue"+attemptsSuffix))
+	assert.False(t, s.Exists("queue"+retryQueueSuffix))
+}
+
+func TestReliableQueue_Ack(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	q := NewReliableQueue(p, "queue", time.Minute)
+
+	_, err := q.DeQueue("c")
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+
+	assert.NoError(t, q.EnQueue("a", []byte("1")))
+	s.HSet("queue"+attemptsSuffix, "a", "1")
+	job, err := q.DeQueue("c")
+	assert.NoError(t, err)
+	assert.Equal(t, &Job{Queue: "queue", Consumer: "c", Key: "a", Data: []byte("1"), Attempts: 1}, job)
+
+	assert.NoError(t, q.Ack(job))
+	assert.False(t, s.Exists(q.processingKey("c")))
+	assert.False(t, s.Exists(q.deadlineKey()))
+	assert.False(t, s.Exists("queue"+attemptsSuffix))
+	assert.ErrorIs(t, q.Ack(job), ErrJobNotFound)
+}
+
+func TestReliableQueue_BDeQueue(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	q := NewReliableQueue(p, "queue", time.Minute)
+
+	_, err := q.BDeQueue("c", 0)
+	assert.Error(t, err)
+	assert.False(t, s.Exists("queue"+consumersSuffix))
+
+	_, err = q.BDeQueue("c", 100*time.Millisecond)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+
+	assert.NoError(t, q.EnQueue("a", []byte("1")))
+	job, err := q.BDeQueue("c", 100*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, &Job{Queue: "queue", Consumer: "c", Key: "a", Data: []byte("1")}, job)
+	members, err := s.ZMembers("queue" + consumersSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, members)
+	assert.NoError(t, q.Ack(job))
+}
+
+func TestReliableQueue_Reap(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	now := time.Now().Truncate(time.Millisecond)
+	s.SetTime(now)
+	q := NewReliableQueue(p, "queue", time.Minute)
+
+	assert.NoError(t, q.EnQueue("a", []byte("1")))
+	assert.NoError(t, q.EnQueue("b", []byte("2")))
+	_, err := q.DeQueue("c1")
+	assert.NoError(t, err)
+
+	// c2 阻塞出队移动了 key 后、认领数据前崩溃
+	_, err = s.ZAdd("queue"+consumersSuffix, float64(now.UnixMilli()), "c2")
+	assert.NoError(t, err)
+	_, err = p.Do("LMOVE", "queue", q.processingKey("c2"), "LEFT", "RIGHT")
+	assert.NoError(t, err)
+
+	// 已退出的消费者及其残留的超时记录
+	_, err = s.ZAdd("queue"+consumersSuffix, float64(now.UnixMilli()), "gone")
+	assert.NoError(t, err)
+	_, err = s.ZAdd(q.deadlineKey(), float64(now.UnixMilli()), "removed|x")
+	assert.NoError(t, err)
+
+	s.SetTime(now.Add(time.Second))
+	n, err := q.Reap(100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	members, err := s.ZMembers("queue" + consumersSuffix)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"c1", "c2"}, members)
+	members, err = s.ZMembers(q.deadlineKey())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"c1|a", "c2|b"}, members)
+
+	// 可见性超时后重新入队，处理中列表为空的消费者在注册过期后删除
+	s.SetTime(now.Add(2 * time.Minute))
+	n, err = q.Reap(100)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	keys, err := s.List("queue")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, keys)
+	assert.Equal(t, "1", s.HGet("queue"+hTable, "a"))
+	assert.Equal(t, "2", s.HGet("queue"+hTable, "b"))
+	assert.False(t, s.Exists(q.processingKey("c1")))
+	assert.False(t, s.Exists(q.processingKey("c2")))
+
+	n, err = q.Reap(100)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.False(t, s.Exists("queue"+consumersSuffix))
+}
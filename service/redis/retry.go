@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryStrategy decides how long to wait before retrying.
+type RetryStrategy interface {
+	// Backoff returns the wait before the attempt-th retry which starts from 1,
+	// a negative duration stops retrying.
+	Backoff(attempt int) time.Duration
+}
+
+// RetryFunc is an adapter to use an ordinary function as a RetryStrategy.
+type RetryFunc func(attempt int) time.Duration
+
+func (f RetryFunc) Backoff(attempt int) time.Duration {
+	return f(attempt)
+}
+
+// NoRetry never retries.
+func NoRetry() RetryStrategy {
+	return RetryFunc(func(int) time.Duration {
+		return -1
+	})
+}
+
+// ConstantBackoff retries at the same interval every time.
+func ConstantBackoff(backoff time.Duration) RetryStrategy {
+	return RetryFunc(func(int) time.Duration {
+		return backoff
+	})
+}
+
+// ExponentialBackoff doubles the wait from min to max on every retry,
+// a random jitter of up to half of the wait is applied to spread the retries of concurrent clients.
+func ExponentialBackoff(min, max time.Duration) RetryStrategy {
+	return RetryFunc(func(attempt int) time.Duration {
+		return jitter(exponential(min, max, attempt))
+	})
+}
+
+// LimitRetry stops the strategy after max retries.
+func LimitRetry(s RetryStrategy, max int) RetryStrategy {
+	return RetryFunc(func(attempt int) time.Duration {
+		if attempt > max {
+			return -1
+		}
+
+		return s.Backoff(attempt)
+	})
+}
+
+// exponential returns min*2^(attempt-1) limited to max.
+func exponential(min, max time.Duration, attempt int) time.Duration {
+	if min <= 0 {
+		min = time.Millisecond
+	}
+	if max < min {
+		max = min
+	}
+
+	backoff := min
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+
+	return backoff
+}
+
+// jitter returns a random duration in [d/2, d].
+func jitter(d time.Duration) time.Duration {
+	if half := d / 2; half > 0 {
+		return half + rand.N(half+1)
+	}
+
+	return d
+}
@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	testCases := []struct {
+		name    string
+		attempt int
+
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			name:    "first",
+			attempt: 1,
+			wantMin: 5 * time.Millisecond,
+			wantMax: 10 * time.Millisecond,
+		},
+		{
+			name:    "third",
+			attempt: 3,
+			wantMin: 20 * time.Millisecond,
+			wantMax: 40 * time.Millisecond,
+		},
+		{
+			name:    "max",
+			attempt: 100,
+			wantMin: 50 * time.Millisecond,
+			wantMax: 100 * time.Millisecond,
+		},
+	}
+
+	s := ExponentialBackoff(10*time.Millisecond, 100*time.Millisecond)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				backoff := s.Backoff(tc.attempt)
+				assert.GreaterOrEqual(t, backoff, tc.wantMin)
+				assert.LessOrEqual(t, backoff, tc.wantMax)
+			}
+		})
+	}
+}
+
+func TestLimitRetry(t *testing.T) {
+	s := LimitRetry(ConstantBackoff(time.Millisecond), 2)
+	assert.Equal(t, time.Millisecond, s.Backoff(1))
+	assert.Equal(t, time.Millisecond, s.Backoff(2))
+	assert.Less(t, s.Backoff(3), time.Duration(0))
+
+	assert.Less(t, NoRetry().Backoff(1), time.Duration(0))
+}
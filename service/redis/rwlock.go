@@ -0,0 +1,154 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 可重入锁及读写锁均使用 hash 存储，field 为持有者的 token，value 为持有次数；
+// 读写锁额外使用 mode 字段记录当前是读锁还是写锁，因此 mode 不能作为读写锁的 token。
+const rwLockModeField = "mode"
+
+const (
+	// ScriptObtainReentrant obtains the lock if it is free or already held by the token.
+	ScriptObtainReentrant = `
+		if redis.call("EXISTS", KEYS[1]) == 0 or redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+			redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+			redis.call("PEXPIRE", KEYS[1], ARGV[2])
+			return 1
+		end
+		return 0
+	`
+	// ScriptObtainRead obtains the read lock if the lock is free or held by readers.
+	ScriptObtainRead = `
+		local mode = redis.call("HGET", KEYS[1], "mode")
+		if mode == false then
+			redis.call("HSET", KEYS[1], "mode", "read")
+		elseif mode ~= "read" then
+			return 0
+		end
+		redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return 1
+	`
+	// ScriptObtainWrite obtains the write lock if the lock is free.
+	ScriptObtainWrite = `
+		if redis.call("EXISTS", KEYS[1]) == 0 then
+			redis.call("HSET", KEYS[1], "mode", "write", ARGV[1], 1)
+			redis.call("PEXPIRE", KEYS[1], ARGV[2])
+			return 1
+		end
+		return 0
+	`
+	// ScriptUnlockHash decreases the hold count of the token, the lock is deleted when no one holds it,
+	// and the release is published to the channel ARGV[2] if given.
+	ScriptUnlockHash = `
+		if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 then
+			return 0
+		end
+		if redis.call("HINCRBY", KEYS[1], ARGV[1], -1) <= 0 then
+			redis.call("HDEL", KEYS[1], ARGV[1])
+			local n = redis.call("HLEN", KEYS[1])
+			if n == 0 or (n == 1 and redis.call("HEXISTS", KEYS[1], "mode") == 1) then
+				redis.call("DEL", KEYS[1])
+				if ARGV[2] then
+					redis.call("PUBLISH", ARGV[2], KEYS[1])
+				end
+			end
+		end
+		return 1
+	`
+	// ScriptRefreshHash resets the ttl of the lock if it is held by the token.
+	ScriptRefreshHash = `
+		if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+			return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		end
+		return 0
+	`
+	// ScriptHashPTTL returns the remaining ttl in milliseconds of the lock held by the token, -1 if not held.
+	ScriptHashPTTL = `
+		if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+			return redis.call("PTTL", KEYS[1])
+		end
+		return -1
+	`
+)
+
+var (
+	scriptObtainReentrant = RegisterScript("lock.obtain_reentrant", 1, ScriptObtainReentrant)
+	scriptObtainRead      = RegisterScript("lock.obtain_read", 1, ScriptObtainRead)
+	scriptObtainWrite     = RegisterScript("lock.obtain_write", 1, ScriptObtainWrite)
+	scriptUnlockHash      = RegisterScript("lock.unlock_hash", 1, ScriptUnlockHash)
+	scriptRefreshHash     = RegisterScript("lock.refresh_hash", 1, ScriptRefreshHash)
+	scriptHashPTTL        = RegisterScript("lock.hash_pttl", 1, ScriptHashPTTL)
+)
+
+var (
+	reentrantScripts = &lockScripts{
+		obtain:       scriptObtainReentrant,
+		unlock:       scriptUnlockHash,
+		unlockNotify: scriptUnlockHash,
+		refresh:      scriptRefreshHash,
+		pttl:         scriptHashPTTL,
+	}
+	readScripts = &lockScripts{
+		obtain:       scriptObtainRead,
+		unlock:       scriptUnlockHash,
+		unlockNotify: scriptUnlockHash,
+		refresh:      scriptRefreshHash,
+		pttl:         scriptHashPTTL,
+	}
+	writeScripts = &lockScripts{
+		obtain:       scriptObtainWrite,
+		unlock:       scriptUnlockHash,
+		unlockNotify: scriptUnlockHash,
+		refresh:      scriptRefreshHash,
+		pttl:         scriptHashPTTL,
+	}
+)
+
+// ObtainReentrant obtains a reentrant lock of key, the holder can obtain it again with the same opt.Token,
+// the lock is freed after every obtained Mutex is released.
+func (l *Locker) ObtainReentrant(ctx context.Context, key string, ttl time.Duration, opt *LockOptions) (*Mutex, error) {
+	return l.obtainLock(ctx, key, ttl, opt, reentrantScripts)
+}
+
+// RWLocker obtains distributed read/write locks, it is the distributed version of syncx.MapKeyLock:
+// many readers can hold the lock of a key at the same time, while a writer holds it exclusively.
+// The readers share the ttl of the lock, refreshing by any reader extends the lock for all of them.
+type RWLocker struct {
+	locker *Locker
+}
+
+// NewRWLocker returns a RWLocker writing the locks to the nodes of l.
+func NewRWLocker(l *Locker) *RWLocker {
+	return &RWLocker{locker: l}
+}
+
+// RLock obtains the read lock of key, ErrNotObtained is returned when a writer holds it.
+func (rw *RWLocker) RLock(ctx context.Context, key string, ttl time.Duration, opt *LockOptions) (*Mutex, error) {
+	if err := checkRWToken(opt); err != nil {
+		return nil, err
+	}
+
+	return rw.locker.obtainLock(ctx, key, ttl, opt, readScripts)
+}
+
+// Lock obtains the write lock of key, ErrNotObtained is returned when a reader or a writer holds it.
+func (rw *RWLocker) Lock(ctx context.Context, key string, ttl time.Duration, opt *LockOptions) (*Mutex, error) {
+	if err := checkRWToken(opt); err != nil {
+		return nil, err
+	}
+
+	return rw.locker.obtainLock(ctx, key, ttl, opt, writeScripts)
+}
+
+// checkRWToken rejects the token which is the same as the mode field of the read/write lock hash.
+func checkRWToken(opt *LockOptions) error {
+	if opt != nil && opt.Token == rwLockModeField {
+		return errors.New("redis: read/write lock token \"mode\" is reserved")
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocker_ObtainReentrant(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	l := NewLocker(p)
+	ctx := context.Background()
+
+	opt := &LockOptions{Token: "owner"}
+	m1, err := l.ObtainReentrant(ctx, "lock", time.Second, opt)
+	assert.NoError(t, err)
+	m2, err := l.ObtainReentrant(ctx, "lock", 2*time.Second, opt)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", s.HGet("lock", "owner"))
+	assert.Equal(t, 2*time.Second, s.TTL("lock"))
+
+	_, err = l.ObtainReentrant(ctx, "lock", time.Second, &LockOptions{Token: "other"})
+	assert.ErrorIs(t, err, ErrNotObtained)
+
+	// 每次获取都释放后锁才被删除
+	assert.NoError(t, m2.Release(ctx))
+	assert.Equal(t, "1", s.HGet("lock", "owner"))
+	ttl, err := m1.TTL(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, ttl)
+
+	assert.NoError(t, m1.Release(ctx))
+	assert.False(t, s.Exists("lock"))
+	assert.ErrorIs(t, m1.Release(ctx), ErrLockNotHeld)
+
+	_, err = l.ObtainReentrant(ctx, "lock", time.Second, &LockOptions{Token: "other"})
+	assert.NoError(t, err)
+}
+
+func TestRWLocker(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	rw := NewRWLocker(NewLocker(p))
+	ctx := context.Background()
+
+	r1, err := rw.RLock(ctx, "lock", time.Second, nil)
+	assert.NoError(t, err)
+	r2, err := rw.RLock(ctx, "lock", time.Second, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "read", s.HGet("lock", "mode"))
+
+	// 有读者时不能加写锁
+	_, err = rw.Lock(ctx, "lock", time.Second, nil)
+	assert.ErrorIs(t, err, ErrNotObtained)
+
+	assert.NoError(t, r1.Release(ctx))
+	_, err = rw.Lock(ctx, "lock", time.Second, nil)
+	assert.ErrorIs(t, err, ErrNotObtained)
+
+	assert.NoError(t, r2.Refresh(ctx, 2*time.Second))
+	assert.Equal(t, 2*time.Second, s.TTL("lock"))
+	assert.NoError(t, r2.Release(ctx))
+	assert.False(t, s.Exists("lock"))
+
+	// 写锁独占
+	w, err := rw.Lock(ctx, "lock", time.Second, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "write", s.HGet("lock", "mode"))
+	_, err = rw.RLock(ctx, "lock", time.Second, nil)
+	assert.ErrorIs(t, err, ErrNotObtained)
+	_, err = rw.Lock(ctx, "lock", time.Second, nil)
+	assert.ErrorIs(t, err, ErrNotObtained)
+
+	assert.NoError(t, w.Release(ctx))
+	assert.False(t, s.Exists("lock"))
+	_, err = rw.RLock(ctx, "lock", time.Second, nil)
+	assert.NoError(t, err)
+}
+
+func TestRWLocker_ModeToken(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	rw := NewRWLocker(NewLocker(p))
+	ctx := context.Background()
+
+	_, err := rw.RLock(ctx, "lock", time.Second, &LockOptions{Token: "mode"})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotObtained)
+	_, err = rw.Lock(ctx, "lock", time.Second, &LockOptions{Token: "mode"})
+	assert.Error(t, err)
+	assert.False(t, s.Exists("lock"))
+}
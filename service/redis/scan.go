@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"iter"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ScanArgs are the options of the scan iterators, the zero value scans all elements.
+type ScanArgs struct {
+	// Match only returns the elements matching the glob-style pattern.
+	Match string
+	// Count is the hint of the number of elements scanned per call.
+	Count int64
+	// Type only returns the keys of the type, e.g. "hash", it is only supported by Scan.
+	Type string
+}
+
+// HField is a field of a hash with its value.
+type HField struct {
+	Name  string
+	Value string
+}
+
+// Scan iterates the keys of the database by SCAN without blocking redis like KEYS, args may be nil.
+// A key may be returned more than once, the iteration stops at the first error, which is yielded as the last element.
+func (p *Pool) Scan(args *ScanArgs) iter.Seq2[string, error] {
+	return scan(p, "SCAN", nil, args, 1, func(elems []any) (string, error) {
+		return redis.String(elems[0], nil)
+	})
+}
+
+// SScan iterates the members of the set by SSCAN, args may be nil, see Scan.
+func (p *Pool) SScan(key string, args *ScanArgs) iter.Seq2[string, error] {
+	return scan(p, "SSCAN", key, args, 1, func(elems []any) (string, error) {
+		return redis.String(elems[0], nil)
+	})
+}
+
+// HScan iterates the fields of the hash by HSCAN, args may be nil, see Scan.
+func (p *Pool) HScan(key string, args *ScanArgs) iter.Seq2[HField, error] {
+	return scan(p, "HSCAN", key, args, 2, func(elems []any) (HField, error) {
+		var field HField
+		_, err := redis.Scan(elems, &field.Name, &field.Value)
+		return field, err
+	})
+}
+
+// ZScan iterates the members of the sorted set with their scores by ZSCAN, args may be nil, see Scan.
+func (p *Pool) ZScan(key string, args *ScanArgs) iter.Seq2[Z, error] {
+	return scan(p, "ZSCAN", key, args, 2, func(elems []any) (Z, error) {
+		var z Z
+		_, err := redis.Scan(elems, &z.Member, &z.Score)
+		return z, err
+	})
+}
+
+// scan iterates a cursor based command, key is nil for SCAN, every step elements are parsed into one value.
+func scan[T any](p *Pool, cmd string, key any, args *ScanArgs, step int, parse func(elems []any) (T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		cursor := "0"
+		for {
+			next, elems, err := scanPage(p.Do(cmd, scanArgs(key, cursor, args)...))
+			if err == nil && len(elems)%step != 0 {
+				err = WrongAnswer
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			for i := 0; i < len(elems); i += step {
+				v, err := parse(elems[i : i+step])
+				if !yield(v, err) || err != nil {
+					return
+				}
+			}
+
+			// 游标回到 0 时迭代结束
+			if next == "0" {
+				return
+			}
+			cursor = next
+		}
+	}
+}
+
+func scanArgs(key any, cursor string, args *ScanArgs) redis.Args {
+	var cmdArgs redis.Args
+	if key != nil {
+		cmdArgs = cmdArgs.Add(key)
+	}
+	cmdArgs = cmdArgs.Add(cursor)
+
+	if args == nil {
+		return cmdArgs
+	}
+	if args.Match != "" {
+		cmdArgs = cmdArgs.Add("MATCH", args.Match)
+	}
+	if args.Count > 0 {
+		cmdArgs = cmdArgs.Add("COUNT", args.Count)
+	}
+	if args.Type != "" && key == nil {
+		cmdArgs = cmdArgs.Add("TYPE", args.Type)
+	}
+
+	return cmdArgs
+}
+
+// scanPage converts a reply of the scan commands into the next cursor and the elements.
+func scanPage(reply any, err error) (string, []any, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(values) != 2 {
+		return "", nil, WrongAnswer
+	}
+
+	cursor, err := redis.String(values[0], nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	elems, err := redis.Values(values[1], nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return cursor, elems, nil
+}
@@ -0,0 +1,204 @@
+package redis
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanArgs(t *testing.T) {
+	testCases := []struct {
+		name string
+		key  any
+		args *ScanArgs
+		want redis.Args
+	}{
+		{
+			name: "scan",
+			want: redis.Args{"0"},
+		},
+		{
+			name: "scan with options",
+			args: &ScanArgs{Match: "user:*", Count: 100, Type: "hash"},
+			want: redis.Args{"0", "MATCH", "user:*", "COUNT", int64(100), "TYPE", "hash"},
+		},
+		{
+			name: "hscan ignores type",
+			key:  "user:1",
+			args: &ScanArgs{Match: "a*", Type: "hash"},
+			want: redis.Args{"user:1", "0", "MATCH", "a*"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, scanArgs(tc.key, "0", tc.args))
+		})
+	}
+}
+
+func TestScanPage(t *testing.T) {
+	cursor, elems, err := scanPage([]any{[]byte("17"), []any{[]byte("a"), []byte("b")}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "17", cursor)
+	assert.Equal(t, []any{[]byte("a"), []byte("b")}, elems)
+
+	_, _, err = scanPage([]any{[]byte("0")}, nil)
+	assert.Equal(t, WrongAnswer, err)
+}
+
+// scanPool returns a pool replying the scan pages in order and records the arguments of the commands.
+func scanPool(t *testing.T, pages ...any) (*Pool, *[][]any) {
+	t.Helper()
+
+	var down atomic.Bool
+	p := newTestPool(&down)
+	conn := &replyConn{replies: pages}
+	p.pools[0].Dial = func() (redis.Conn, error) { return conn, nil }
+
+	var args [][]any
+	p.AddHook(HookFuncs{Before: func(info *CmdInfo) {
+		args = append(args, info.Args)
+	}})
+
+	return p, &args
+}
+
+func scanReply(cursor string, elems ...string) []any {
+	values := make([]any, 0, len(elems))
+	for _, elem := range elems {
+		values = append(values, []byte(elem))
+	}
+
+	return []any{[]byte(cursor), values}
+}
+
+func TestPool_ScanPages(t *testing.T) {
+	// 游标跨越多页，直到服务端返回游标 0
+	p, args := scanPool(t, scanReply("17", "a", "b"), scanReply("5"), scanReply("0", "c"))
+
+	var keys []string
+	for key, err := range p.Scan(&ScanArgs{Match: "*", Count: 2}) {
+		assert.NoError(t, err)
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+	assert.Equal(t, [][]any{
+		{"0", "MATCH", "*", "COUNT", int64(2)},
+		{"17", "MATCH", "*", "COUNT", int64(2)},
+		{"5", "MATCH", "*", "COUNT", int64(2)},
+	}, *args)
+
+	// 提前 break 时不再请求下一页
+	p, args = scanPool(t, scanReply("17", "a", "b"), scanReply("0", "c"))
+	for key, err := range p.Scan(nil) {
+		assert.NoError(t, err)
+		assert.Equal(t, "a", key)
+		break
+	}
+	assert.Len(t, *args, 1)
+
+	// 出错时错误作为最后一个元素返回
+	wrongType := redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	p, args = scanPool(t, scanReply("17", "a", "b"), wrongType)
+	var errs []error
+	for _, err := range p.SScan("set", &ScanArgs{Count: 2}) {
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{nil, nil, wrongType}, errs)
+	assert.Equal(t, []any{"set", "17", "COUNT", int64(2)}, (*args)[1])
+}
+
+func TestPool_HScanPages(t *testing.T) {
+	p, args := scanPool(t, scanReply("3", "a", "1"), scanReply("0", "b", "2"))
+
+	var fields []HField
+	for f, err := range p.HScan("hash", &ScanArgs{Count: 1}) {
+		assert.NoError(t, err)
+		fields = append(fields, f)
+	}
+	assert.Equal(t, []HField{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}, fields)
+	assert.Equal(t, []any{"hash", "3", "COUNT", int64(1)}, (*args)[1])
+
+	// 字段与值不成对时返回 WrongAnswer
+	p, _ = scanPool(t, scanReply("0", "a"))
+	for _, err := range p.HScan("hash", nil) {
+		assert.Equal(t, WrongAnswer, err)
+	}
+}
+
+// miniredis 忽略 COUNT，一次返回全部元素，以下用例验证命令与回复的解析
+func TestPool_Scan(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	var want []string
+	for i := 0; i < 25; i++ {
+		key := "user:" + strconv.Itoa(i)
+		assert.NoError(t, s.Set(key, "v"))
+		want = append(want, key)
+	}
+	assert.NoError(t, s.Set("other", "v"))
+
+	var keys []string
+	for key, err := range p.Scan(&ScanArgs{Match: "user:*", Count: 10}) {
+		assert.NoError(t, err)
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, want, keys)
+}
+
+func TestPool_HScan(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	want := make(map[string]string)
+	for i := 0; i < 25; i++ {
+		field := "f" + strconv.Itoa(i)
+		s.HSet("hash", field, strconv.Itoa(i))
+		want[field] = strconv.Itoa(i)
+	}
+
+	got := make(map[string]string)
+	for f, err := range p.HScan("hash", &ScanArgs{Count: 10}) {
+		assert.NoError(t, err)
+		got[f.Name] = f.Value
+	}
+	assert.Equal(t, want, got)
+
+	assert.NoError(t, s.Set("string", "v"))
+	var errs []error
+	for _, err := range p.HScan("string", nil) {
+		errs = append(errs, err)
+	}
+	if assert.Len(t, errs, 1) {
+		assert.ErrorContains(t, errs[0], "WRONGTYPE")
+	}
+}
+
+func TestPool_SScanZScan(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	var want []string
+	for i := 0; i < 25; i++ {
+		member := "m" + strconv.Itoa(i)
+		_, err := s.SAdd("set", member)
+		assert.NoError(t, err)
+		_, err = s.ZAdd("zset", float64(i), member)
+		assert.NoError(t, err)
+		want = append(want, member)
+	}
+
+	var members []string
+	for member, err := range p.SScan("set", &ScanArgs{Count: 10}) {
+		assert.NoError(t, err)
+		members = append(members, member)
+	}
+	assert.ElementsMatch(t, want, members)
+
+	scores := make(map[string]float64)
+	for z, err := range p.ZScan("zset", &ScanArgs{Match: "m1*", Count: 5}) {
+		assert.NoError(t, err)
+		scores[z.Member] = z.Score
+	}
+	assert.Len(t, scores, 11)
+	assert.Equal(t, float64(12), scores["m12"])
+}
@@ -0,0 +1,125 @@
+package redis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Script is a registered Lua script, it is run by EVALSHA and falls back to EVAL when the server has not cached it.
+type Script struct {
+	name     string
+	keyCount int
+	src      string
+	hash     string
+}
+
+var scriptRegistry = struct {
+	mu      sync.RWMutex
+	scripts map[string]*Script
+}{scripts: make(map[string]*Script)}
+
+// RegisterScript registers the Lua script with keyCount keys under the name, it is usually called in package level var declarations.
+// Registering the same name again returns the existing script if the source is the same, otherwise it panics.
+func RegisterScript(name string, keyCount int, src string) *Script {
+	sum := sha1.Sum([]byte(src))
+	s := &Script{name: name, keyCount: keyCount, src: src, hash: hex.EncodeToString(sum[:])}
+
+	scriptRegistry.mu.Lock()
+	defer scriptRegistry.mu.Unlock()
+
+	if old, ok := scriptRegistry.scripts[name]; ok {
+		if old.hash != s.hash || old.keyCount != s.keyCount {
+			panic(fmt.Sprintf("redis: script %s registered twice with different sources", name))
+		}
+
+		return old
+	}
+	scriptRegistry.scripts[name] = s
+
+	return s
+}
+
+// LookupScript returns the script registered under the name.
+func LookupScript(name string) (*Script, bool) {
+	scriptRegistry.mu.RLock()
+	defer scriptRegistry.mu.RUnlock()
+
+	s, ok := scriptRegistry.scripts[name]
+	return s, ok
+}
+
+func (s *Script) Name() string {
+	return s.name
+}
+
+// Hash returns the SHA1 digest of the source used by EVALSHA.
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+func (s *Script) KeyCount() int {
+	return s.keyCount
+}
+
+// doer runs a command, e.g. Pool and a Redlock instance.
+type doer interface {
+	Do(cmdStr string, args ...any) (any, error)
+}
+
+// evalScript runs the script with EVALSHA and retries with EVAL if the server replies NOSCRIPT,
+// which also caches the script on the server for the next EVALSHA.
+func evalScript(d doer, s *Script, keysAndArgs ...any) (any, error) {
+	return evalScriptKeys(d, s, s.keyCount, keysAndArgs...)
+}
+
+// evalScriptKeys is evalScript with keyCount keys, for the scripts taking a variable number of keys.
+func evalScriptKeys(d doer, s *Script, keyCount int, keysAndArgs ...any) (any, error) {
+	args := make([]any, 0, len(keysAndArgs)+2)
+	args = append(args, s.hash, keyCount)
+	args = append(args, keysAndArgs...)
+
+	reply, err := d.Do("EVALSHA", args...)
+
+	var replyErr redis.Error
+	if errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "NOSCRIPT") {
+		args[0] = s.src
+		return d.Do("EVAL", args...)
+	}
+
+	return reply, err
+}
+
+// EvalScript runs the registered script with the keys and then the arguments through Do.
+func (p *Pool) EvalScript(s *Script, keysAndArgs ...any) (any, error) {
+	return evalScript(p, s, keysAndArgs...)
+}
+
+// LoadScripts loads all registered scripts into the script cache of every server, e.g. before failing over to a backup,
+// it is optional since a script is cached on the first EVAL fallback.
+func (p *Pool) LoadScripts() error {
+	scriptRegistry.mu.RLock()
+	scripts := make([]*Script, 0, len(scriptRegistry.scripts))
+	for _, s := range scriptRegistry.scripts {
+		scripts = append(scripts, s)
+	}
+	scriptRegistry.mu.RUnlock()
+
+	var errs []error
+	for _, rp := range p.pools {
+		node := instance{pool: rp}
+		for _, s := range scripts {
+			if _, err := node.Do("SCRIPT", "LOAD", s.src); err != nil {
+				errs = append(errs, fmt.Errorf("load script %s: %w", s.name, err))
+				break
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
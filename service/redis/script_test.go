@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptDoer caches the scripts evaluated by EVAL like a redis server.
+type scriptDoer struct {
+	cached map[string]bool
+	cmds   []string
+}
+
+func (d *scriptDoer) Do(cmdStr string, args ...any) (any, error) {
+	d.cmds = append(d.cmds, cmdStr)
+
+	switch cmdStr {
+	case "EVALSHA":
+		if !d.cached[args[0].(string)] {
+			return nil, redis.Error("NOSCRIPT No matching script. Please use EVAL.")
+		}
+	case "EVAL":
+		d.cached[scriptHPop.Hash()] = args[0] == ScriptHPop
+	}
+
+	return args[2:], nil
+}
+
+func TestEvalScript(t *testing.T) {
+	d := &scriptDoer{cached: make(map[string]bool)}
+
+	reply, err := evalScript(d, scriptHPop, "table", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"table", "key"}, reply)
+	assert.Equal(t, []string{"EVALSHA", "EVAL"}, d.cmds)
+
+	_, err = evalScript(d, scriptHPop, "table", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"EVALSHA", "EVAL", "EVALSHA"}, d.cmds)
+}
+
+func TestRegisterScript(t *testing.T) {
+	s, ok := LookupScript("hpop")
+	assert.True(t, ok)
+	assert.Same(t, scriptHPop, s)
+	assert.Equal(t, "hpop", s.Name())
+	assert.Equal(t, 1, s.KeyCount())
+	assert.Len(t, s.Hash(), 40)
+
+	assert.Same(t, scriptHPop, RegisterScript("hpop", 1, ScriptHPop))
+	assert.Panics(t, func() { RegisterScript("hpop", 1, "return 1") })
+}
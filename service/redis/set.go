@@ -12,26 +12,7 @@ func (p *Pool) RmFromSet(key, val string) error {
 	return err
 }
 
+// GetsFromSet returns all members of the set, use SScan for big sets since SMEMBERS blocks redis.
 func (p *Pool) GetsFromSet(key string) ([]string, error) {
-	reply, err := p.Do("SMEMBERS", key)
-	if err != nil {
-		return nil, err
-	}
-
-	elems, ok := reply.([]any)
-	if !ok {
-		return nil, WrongAnswer
-	}
-
-	results := make([]string, 0, len(elems))
-	for _, elem := range elems {
-		val, ok := elem.([]byte)
-		if !ok {
-			continue
-		}
-
-		results = append(results, string(val))
-	}
-
-	return results, nil
+	return redis.Strings(p.Do("SMEMBERS", key))
 }
@@ -0,0 +1,406 @@
+package redis
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// XMessage is an entry of a stream.
+type XMessage struct {
+	ID     string
+	Values map[string]string
+}
+
+// XStream is the entries read from a stream.
+type XStream struct {
+	Stream   string
+	Messages []XMessage
+}
+
+// XPendingSummary is the summary of the pending entries of a consumer group.
+type XPendingSummary struct {
+	Count     int64
+	Lower     string
+	Upper     string
+	Consumers map[string]int64
+}
+
+// XPendingEntry is a pending entry delivered to a consumer but not acknowledged.
+type XPendingEntry struct {
+	ID            string
+	Consumer      string
+	Idle          time.Duration
+	DeliveryCount int64
+}
+
+// XAddArgs are the arguments of XAdd.
+type XAddArgs struct {
+	Stream string
+	// ID of the entry, "*" if empty.
+	ID string
+	// MaxLen or MinID trims the stream, Approx uses "~" for efficient trimming.
+	MaxLen int64
+	MinID  string
+	Approx bool
+	Values map[string]any
+}
+
+// XReadGroupArgs are the arguments of XReadGroup.
+type XReadGroupArgs struct {
+	Group    string
+	Consumer string
+	// Streams are the stream keys, IDs are the ids of them, ">" for the new entries if empty.
+	Streams []string
+	IDs     []string
+	Count   int64
+	// Block waits at most the duration for new entries if positive, it is rounded up to milliseconds,
+	// since BLOCK 0 blocks forever.
+	Block time.Duration
+	NoAck bool
+}
+
+// XClaimArgs are the arguments of XClaim and XAutoClaim.
+type XClaimArgs struct {
+	Stream   string
+	Group    string
+	Consumer string
+	// MinIdle only claims the entries idle for at least the duration.
+	MinIdle time.Duration
+}
+
+// trimArgs returns the MAXLEN or MINID arguments.
+func trimArgs(maxLen int64, minID string, approx bool) redis.Args {
+	var args redis.Args
+	switch {
+	case maxLen > 0:
+		args = args.Add("MAXLEN")
+	case minID != "":
+		args = args.Add("MINID")
+	default:
+		return nil
+	}
+
+	if approx {
+		args = args.Add("~")
+	}
+	if maxLen > 0 {
+		return args.Add(maxLen)
+	}
+
+	return args.Add(minID)
+}
+
+// XAdd appends an entry to the stream and returns its id.
+func (p *Pool) XAdd(a *XAddArgs) (string, error) {
+	id := a.ID
+	if id == "" {
+		id = "*"
+	}
+
+	args := redis.Args{}.Add(a.Stream).AddFlat(trimArgs(a.MaxLen, a.MinID, a.Approx)).Add(id)
+	for field, value := range a.Values {
+		args = args.Add(field, value)
+	}
+
+	return redis.String(p.Do("XADD", args...))
+}
+
+// XLen returns the number of entries of the stream.
+func (p *Pool) XLen(stream string) (int64, error) {
+	return redis.Int64(p.Do("XLEN", stream))
+}
+
+// XTrimMaxLen trims the stream to maxLen entries, it returns the number of deleted entries.
+func (p *Pool) XTrimMaxLen(stream string, maxLen int64, approx bool) (int64, error) {
+	return redis.Int64(p.Do("XTRIM", redis.Args{}.Add(stream).AddFlat(trimArgs(maxLen, "", approx))...))
+}
+
+// XTrimMinID deletes the entries with ids lower than minID, it returns the number of deleted entries.
+func (p *Pool) XTrimMinID(stream, minID string, approx bool) (int64, error) {
+	return redis.Int64(p.Do("XTRIM", redis.Args{}.Add(stream).AddFlat(trimArgs(0, minID, approx))...))
+}
+
+// XDel deletes the entries of the stream, it returns the number of deleted entries.
+func (p *Pool) XDel(stream string, ids ...string) (int64, error) {
+	return redis.Int64(p.Do("XDEL", redis.Args{}.Add(stream).AddFlat(ids)...))
+}
+
+// XRange returns the entries with ids between start and end, count limits the number if positive.
+func (p *Pool) XRange(stream, start, end string, count int64) ([]XMessage, error) {
+	args := redis.Args{}.Add(stream, start, end)
+	if count > 0 {
+		args = args.Add("COUNT", count)
+	}
+
+	return xMessages(p.Do("XRANGE", args...))
+}
+
+// XGroupCreate creates the consumer group starting from id, "$" for the new entries,
+// the stream is created if mkStream is true, creating an existing group is not an error.
+func (p *Pool) XGroupCreate(stream, group, id string, mkStream bool) error {
+	args := redis.Args{}.Add("CREATE", stream, group, id)
+	if mkStream {
+		args = args.Add("MKSTREAM")
+	}
+
+	_, err := p.Do("XGROUP", args...)
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+
+	return err
+}
+
+// XGroupDestroy destroys the consumer group.
+func (p *Pool) XGroupDestroy(stream, group string) error {
+	_, err := p.Do("XGROUP", "DESTROY", stream, group)
+	return err
+}
+
+// XGroupDelConsumer deletes the consumer from the group, it returns the number of its pending entries.
+func (p *Pool) XGroupDelConsumer(stream, group, consumer string) (int64, error) {
+	return redis.Int64(p.Do("XGROUP", "DELCONSUMER", stream, group, consumer))
+}
+
+// XReadGroup reads the entries of the streams as the consumer of the group,
+// an empty result is returned if no entry is available before the block time.
+func (p *Pool) XReadGroup(a *XReadGroupArgs) ([]XStream, error) {
+	if len(a.IDs) > 0 && len(a.IDs) != len(a.Streams) {
+		return nil, errors.New("redis: the number of stream ids does not match the streams")
+	}
+
+	args := redis.Args{}.Add("GROUP", a.Group, a.Consumer)
+	if a.Count > 0 {
+		args = args.Add("COUNT", a.Count)
+	}
+	if a.Block > 0 {
+		args = args.Add("BLOCK", (a.Block + time.Millisecond - 1).Milliseconds())
+	}
+	if a.NoAck {
+		args = args.Add("NOACK")
+	}
+	args = args.Add("STREAMS").AddFlat(a.Streams)
+	if len(a.IDs) > 0 {
+		args = args.AddFlat(a.IDs)
+	} else {
+		for range a.Streams {
+			args = args.Add(">")
+		}
+	}
+
+	var reply any
+	var err error
+	if a.Block > 0 {
+		// 阻塞读取时连接的读超时需要大于阻塞时间
+		reply, err = p.DoWithTimeout(a.Block+time.Second, "XREADGROUP", args...)
+	} else {
+		reply, err = p.Do("XREADGROUP", args...)
+	}
+
+	// 阻塞超时没有新消息时回复为 nil
+	streams, err := xStreams(reply, err)
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+
+	return streams, err
+}
+
+// XAck acknowledges the entries of the group, it returns the number of acknowledged entries.
+func (p *Pool) XAck(stream, group string, ids ...string) (int64, error) {
+	return redis.Int64(p.Do("XACK", redis.Args{}.Add(stream, group).AddFlat(ids)...))
+}
+
+// XPending returns the summary of the pending entries of the group.
+func (p *Pool) XPending(stream, group string) (*XPendingSummary, error) {
+	elems, err := redis.Values(p.Do("XPENDING", stream, group))
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) != 4 {
+		return nil, WrongAnswer
+	}
+
+	summary := &XPendingSummary{Consumers: make(map[string]int64)}
+	if summary.Count, err = redis.Int64(elems[0], nil); err != nil {
+		return nil, err
+	}
+	if summary.Count == 0 {
+		return summary, nil
+	}
+	if summary.Lower, err = redis.String(elems[1], nil); err != nil {
+		return nil, err
+	}
+	if summary.Upper, err = redis.String(elems[2], nil); err != nil {
+		return nil, err
+	}
+
+	consumers, err := redis.Values(elems[3], nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, consumer := range consumers {
+		pair, err := redis.Strings(consumer, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(pair) != 2 {
+			return nil, WrongAnswer
+		}
+
+		n, err := redis.Int64([]byte(pair[1]), nil)
+		if err != nil {
+			return nil, err
+		}
+		summary.Consumers[pair[0]] = n
+	}
+
+	return summary, nil
+}
+
+// XPendingExt returns at most count pending entries of the group with ids between start and end,
+// consumer filters the entries of the consumer if not empty.
+func (p *Pool) XPendingExt(stream, group, start, end string, count int64, consumer string) ([]XPendingEntry, error) {
+	args := redis.Args{}.Add(stream, group, start, end, count)
+	if consumer != "" {
+		args = args.Add(consumer)
+	}
+
+	elems, err := redis.Values(p.Do("XPENDING", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]XPendingEntry, 0, len(elems))
+	for _, elem := range elems {
+		fields, err := redis.Values(elem, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != 4 {
+			return nil, WrongAnswer
+		}
+
+		var entry XPendingEntry
+		var idle int64
+		if _, err = redis.Scan(fields, &entry.ID, &entry.Consumer, &idle, &entry.DeliveryCount); err != nil {
+			return nil, err
+		}
+		entry.Idle = time.Duration(idle) * time.Millisecond
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// XClaim changes the owner of the pending entries idle for at least a.MinIdle to a.Consumer and returns the claimed entries.
+func (p *Pool) XClaim(a *XClaimArgs, ids ...string) ([]XMessage, error) {
+	args := redis.Args{}.Add(a.Stream, a.Group, a.Consumer, a.MinIdle.Milliseconds()).AddFlat(ids)
+
+	return xMessages(p.Do("XCLAIM", args...))
+}
+
+// XAutoClaim claims at most count pending entries idle for at least a.MinIdle starting from start,
+// it returns the claimed entries and the id to start the next call from, "0-0" if all entries are scanned.
+func (p *Pool) XAutoClaim(a *XClaimArgs, start string, count int64) ([]XMessage, string, error) {
+	args := redis.Args{}.Add(a.Stream, a.Group, a.Consumer, a.MinIdle.Milliseconds(), start)
+	if count > 0 {
+		args = args.Add("COUNT", count)
+	}
+
+	elems, err := redis.Values(p.Do("XAUTOCLAIM", args...))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(elems) < 2 {
+		return nil, "", WrongAnswer
+	}
+
+	next, err := redis.String(elems[0], nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	messages, err := xMessages(elems[1], nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return messages, next, nil
+}
+
+// xMessages converts a reply of entries, the values of the entries deleted from the stream are nil.
+func xMessages(reply any, err error) ([]XMessage, error) {
+	elems, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]XMessage, 0, len(elems))
+	for _, elem := range elems {
+		entry, err := redis.Values(elem, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(entry) != 2 {
+			return nil, WrongAnswer
+		}
+
+		id, err := redis.String(entry[0], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// 已删除的消息返回的字段为 nil，保留 id 以便确认
+		if entry[1] == nil {
+			messages = append(messages, XMessage{ID: id})
+			continue
+		}
+
+		values, err := redis.StringMap(entry[1], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, XMessage{ID: id, Values: values})
+	}
+
+	return messages, nil
+}
+
+// xStreams converts a reply of XREAD and XREADGROUP.
+func xStreams(reply any, err error) ([]XStream, error) {
+	elems, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	streams := make([]XStream, 0, len(elems))
+	for _, elem := range elems {
+		stream, err := redis.Values(elem, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(stream) != 2 {
+			return nil, WrongAnswer
+		}
+
+		name, err := redis.String(stream[0], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		messages, err := xMessages(stream[1], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		streams = append(streams, XStream{Stream: name, Messages: messages})
+	}
+
+	return streams, nil
+}
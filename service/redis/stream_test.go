@@ -0,0 +1,151 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXStreams(t *testing.T) {
+	reply := []any{
+		[]any{
+			[]byte("orders"),
+			[]any{
+				[]any{[]byte("1-0"), []any{[]byte("id"), []byte("1"), []byte("state"), []byte("paid")}},
+				[]any{[]byte("2-0"), nil},
+			},
+		},
+	}
+
+	streams, err := xStreams(reply, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []XStream{
+		{
+			Stream: "orders",
+			Messages: []XMessage{
+				{ID: "1-0", Values: map[string]string{"id": "1", "state": "paid"}},
+				{ID: "2-0"},
+			},
+		},
+	}, streams)
+
+	_, err = xStreams([]any{[]any{[]byte("orders")}}, nil)
+	assert.Equal(t, WrongAnswer, err)
+}
+
+func TestTrimArgs(t *testing.T) {
+	testCases := []struct {
+		name   string
+		maxLen int64
+		minID  string
+		approx bool
+
+		want redis.Args
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:   "max len",
+			maxLen: 100,
+			want:   redis.Args{"MAXLEN", int64(100)},
+		},
+		{
+			name:   "approx min id",
+			minID:  "1-0",
+			approx: true,
+			want:   redis.Args{"MINID", "~", "1-0"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, trimArgs(tc.maxLen, tc.minID, tc.approx))
+		})
+	}
+}
+
+func TestPool_XReadGroup(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+
+	assert.NoError(t, p.XGroupCreate("orders", "group", "$", true))
+	// 重复创建消费组不报错
+	assert.NoError(t, p.XGroupCreate("orders", "group", "$", true))
+
+	var ids []string
+	for _, state := range []string{"paid", "shipped", "done"} {
+		id, err := p.XAdd(&XAddArgs{Stream: "orders", Values: map[string]any{"state": state}})
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	n, err := p.XLen("orders")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	streams, err := p.XReadGroup(&XReadGroupArgs{Group: "group", Consumer: "c1", Streams: []string{"orders"}, Count: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []XStream{{Stream: "orders", Messages: []XMessage{
+		{ID: ids[0], Values: map[string]string{"state": "paid"}},
+		{ID: ids[1], Values: map[string]string{"state": "shipped"}},
+	}}}, streams)
+
+	acked, err := p.XAck("orders", "group", ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), acked)
+
+	summary, err := p.XPending("orders", "group")
+	assert.NoError(t, err)
+	assert.Equal(t, &XPendingSummary{Count: 1, Lower: ids[1], Upper: ids[1], Consumers: map[string]int64{"c1": 1}}, summary)
+
+	// 其他消费者认领未确认的消息
+	claimed, err := p.XClaim(&XClaimArgs{Stream: "orders", Group: "group", Consumer: "c2"}, ids[1])
+	assert.NoError(t, err)
+	assert.Equal(t, []XMessage{{ID: ids[1], Values: map[string]string{"state": "shipped"}}}, claimed)
+	entries, err := p.XPendingExt("orders", "group", "-", "+", 10, "c2")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, ids[1], entries[0].ID)
+		assert.Equal(t, int64(2), entries[0].DeliveryCount)
+	}
+	claimed, next, err := p.XAutoClaim(&XClaimArgs{Stream: "orders", Group: "group", Consumer: "c1"}, "0-0", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "0-0", next)
+	assert.Equal(t, []XMessage{{ID: ids[1], Values: map[string]string{"state": "shipped"}}}, claimed)
+
+	// 读取剩余的消息，没有新消息时阻塞到超时返回空结果
+	streams, err = p.XReadGroup(&XReadGroupArgs{Group: "group", Consumer: "c1", Streams: []string{"orders"}, Block: 50 * time.Millisecond})
+	assert.NoError(t, err)
+	if assert.Len(t, streams, 1) {
+		assert.Equal(t, []XMessage{{ID: ids[2], Values: map[string]string{"state": "done"}}}, streams[0].Messages)
+	}
+	streams, err = p.XReadGroup(&XReadGroupArgs{Group: "group", Consumer: "c1", Streams: []string{"orders"}, Block: 50 * time.Millisecond})
+	assert.NoError(t, err)
+	assert.Empty(t, streams)
+
+	_, err = p.XReadGroup(&XReadGroupArgs{Group: "group", Consumer: "c1", Streams: []string{"orders"}, IDs: []string{"0", "0"}})
+	assert.Error(t, err)
+
+	assert.NoError(t, p.XGroupDestroy("orders", "group"))
+}
+
+func TestPool_XReadGroupSubMillisecondBlock(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+	assert.NoError(t, p.XGroupCreate("orders", "group", "$", true))
+
+	var args []any
+	p.AddHook(HookFuncs{Before: func(info *CmdInfo) {
+		if info.Name == "XREADGROUP" {
+			args = info.Args
+		}
+	}})
+
+	// 不足 1ms 的阻塞时间向上取整，BLOCK 0 会永久阻塞
+	start := time.Now()
+	streams, err := p.XReadGroup(&XReadGroupArgs{Group: "group", Consumer: "c1", Streams: []string{"orders"}, Block: 500 * time.Microsecond})
+	assert.NoError(t, err)
+	assert.Empty(t, streams)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []any{"GROUP", "group", "c1", "BLOCK", int64(1), "STREAMS", "orders", ">"}, args)
+}
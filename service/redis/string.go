@@ -3,6 +3,9 @@ package redis
 import (
 	"fmt"
 	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
 )
 
 func (p *Pool) Get(key string) (any, error) {
@@ -14,6 +17,21 @@ func (p *Pool) Get(key string) (any, error) {
 	return result, nil
 }
 
+// GetString returns the value of the key, ErrNil is returned if the key does not exist.
+func (p *Pool) GetString(key string) (string, error) {
+	return redis.String(p.Do("GET", key))
+}
+
+// GetBytes returns the value of the key, ErrNil is returned if the key does not exist.
+func (p *Pool) GetBytes(key string) ([]byte, error) {
+	return redis.Bytes(p.Do("GET", key))
+}
+
+// GetInt64 returns the value of the key as an integer, ErrNil is returned if the key does not exist.
+func (p *Pool) GetInt64(key string) (int64, error) {
+	return redis.Int64(p.Do("GET", key))
+}
+
 func (p *Pool) GetSet(key, value string) (any, error) {
 	result, err := p.Do("GETSET", key, value)
 	if err != nil {
@@ -100,6 +118,11 @@ func (p *Pool) Incr(key string) (int64, error) {
 	return id, err
 }
 
+// IncrBy increments the integer value of the key by increment and returns the new value.
+func (p *Pool) IncrBy(key string, increment int64) (int64, error) {
+	return redis.Int64(p.Do("INCRBY", key, increment))
+}
+
 func (p *Pool) GetTime() (second, microSecond int64, err error) {
 	result, err := p.Do("TIME")
 	if err != nil {
@@ -140,3 +163,23 @@ func (p *Pool) GetTime() (second, microSecond int64, err error) {
 
 	return
 }
+
+// SetNxEx sets key to value with an expire time of seconds only if the key does not exist, it reports whether the key was set.
+func (p *Pool) SetNxEx(key, value string, seconds int) (bool, error) {
+	result, err := p.Do("SET", key, value, "NX", "EX", seconds)
+	if err != nil {
+		return false, err
+	}
+
+	return result != nil, nil
+}
+
+// ServerTime returns the time of the redis server, it is a clock shared by all clients.
+func (p *Pool) ServerTime() (time.Time, error) {
+	second, microSecond, err := p.GetTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(second, microSecond*int64(time.Microsecond)), nil
+}
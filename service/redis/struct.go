@@ -0,0 +1,389 @@
+package redis
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dapings/kit/std"
+	"github.com/dapings/kit/stringx"
+	"github.com/gomodule/redigo/redis"
+)
+
+// structTag is the tag of the struct fields mapped to hash fields, e.g. `redis:"name,omitempty"`,
+// the snake_case of the field name is used if the name is empty, "-" skips the field.
+// NOTE: stringx.CamelToSnake splits every upper letter, e.g. ID -> i_d, tag such fields explicitly.
+const structTag = "redis"
+
+// nestedSeparator joins the names of a nested struct field, e.g. address.city.
+const nestedSeparator = "."
+
+// ScriptHSetStruct deletes the fields ARGV[2..ARGV[1]+1] and sets the field and value pairs after them.
+// KEYS: hash
+// ARGV: number of the deleted fields, deleted fields..., field, value...
+const ScriptHSetStruct = `
+	local n = tonumber(ARGV[1])
+	if n > 0 then
+		redis.call("HDEL", KEYS[1], unpack(ARGV, 2, n + 1))
+	end
+	if #ARGV > n + 1 then
+		redis.call("HSET", KEYS[1], unpack(ARGV, n + 2))
+	end
+	return n
+`
+
+var scriptHSetStruct = RegisterScript("hash.set_struct", 1, ScriptHSetStruct)
+
+var (
+	errNotStruct = errors.New("redis: value is not a struct or a pointer to struct")
+
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// HSetStruct stores the exported fields of v into the hash, v is a struct or a pointer to struct.
+// The fields of nested structs are stored as <field>.<nested field>, the fields of embedded structs are promoted,
+// types implementing encoding.TextMarshaler are stored as text.
+// Nil pointers and zero values with omitempty are deleted from the hash in the same script,
+// so the hash keeps no stale values of them, the hash fields not mapped to v are kept.
+func (p *Pool) HSetStruct(hTable string, v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errNotStruct
+	}
+
+	fields := &structFields{}
+	if err := fields.addStruct("", rv); err != nil {
+		return err
+	}
+	if len(fields.args) == 0 && len(fields.deleted) == 0 {
+		return nil
+	}
+
+	_, err := p.EvalScript(scriptHSetStruct, redis.Args{}.Add(hTable, len(fields.deleted)).AddFlat(fields.deleted).AddFlat(fields.args)...)
+	return err
+}
+
+// HGetStruct loads the hash into the fields of v mapped like HSetStruct, v is a non-nil pointer to struct.
+// The fields missing in the hash are left unchanged, ErrNil is returned if the hash does not exist.
+func (p *Pool) HGetStruct(hTable string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errNotStruct
+	}
+
+	values, err := p.HGetAll(hTable)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return ErrNil
+	}
+
+	_, err = setStruct(values, "", rv.Elem())
+	return err
+}
+
+// fieldName returns the hash field name of the struct field, promoted reports whether the fields of an embedded struct are promoted.
+func fieldName(field reflect.StructField) (name string, omitEmpty, promoted, skip bool) {
+	tag := field.Tag.Get(structTag)
+	if tag == "-" {
+		return "", false, false, true
+	}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	omitEmpty = opts == "omitempty"
+
+	if name == "" && field.Anonymous && isStruct(field.Type) {
+		return "", omitEmpty, true, false
+	}
+	if !field.IsExported() {
+		return "", false, false, true
+	}
+	if name == "" {
+		name = stringx.CamelToSnake(field.Name)
+	}
+
+	return name, omitEmpty, false, false
+}
+
+// isStruct reports whether t is a struct or a pointer to struct mapped field by field.
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && !t.Implements(textMarshalerType)
+}
+
+// isMethodInterface reports whether t is an interface with methods, i.e. not any,
+// the fields of such types can be stored but not loaded back, so they are not supported.
+func isMethodInterface(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.NumMethod() > 0
+}
+
+// structFields collects the hash fields of a struct for HSetStruct.
+type structFields struct {
+	// args are the field and value pairs to set
+	args []string
+	// deleted are the fields of nil pointers and zero values with omitempty
+	deleted []string
+}
+
+// addStruct adds the fields of the struct v.
+func (f *structFields) addStruct(prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, omitEmpty, promoted, skip := fieldName(t.Field(i))
+		if skip {
+			continue
+		}
+		if !promoted {
+			name = prefix + name
+		}
+
+		fv := v.Field(i)
+		if omitEmpty && fv.IsZero() {
+			f.deleted = typeFields(f.deleted, nil, prefix, name, fv.Type())
+			continue
+		}
+
+		if err := f.addField(prefix, name, fv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addField adds the field v named name, an empty name promotes the fields of a struct.
+func (f *structFields) addField(prefix, name string, v reflect.Value) error {
+	t := v.Type()
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if isMethodInterface(v.Type()) {
+			return fmt.Errorf("redis: field %s of %v is not supported", name, v.Type())
+		}
+		if v.IsNil() {
+			f.deleted = typeFields(f.deleted, nil, prefix, name, t)
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type().Implements(textMarshalerType) && name != "" {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return fmt.Errorf("redis: marshal field %s: %w", name, err)
+		}
+
+		f.args = append(f.args, name, string(text))
+		return nil
+	}
+
+	if v.Kind() == reflect.Struct {
+		if name != "" {
+			prefix = name + nestedSeparator
+		}
+
+		return f.addStruct(prefix, v)
+	}
+	if name == "" {
+		// 非结构体的嵌入字段没有字段名，无法映射
+		return nil
+	}
+
+	var value string
+	switch v.Kind() {
+	case reflect.Bool:
+		value = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		value = strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	case reflect.String:
+		value = v.String()
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("redis: field %s of %v is not supported", name, v.Type())
+		}
+		value = string(v.Bytes())
+	default:
+		return fmt.Errorf("redis: field %s of %v is not supported", name, v.Type())
+	}
+
+	f.args = append(f.args, name, value)
+	return nil
+}
+
+// typeFields appends the hash fields that a value of type t named name is stored as,
+// i.e. the fields of a struct or the name itself.
+// The struct types in path are not expanded again, so a recursive type only deletes its first level.
+func typeFields(fields []string, path []reflect.Type, prefix, name string, t reflect.Type) []string {
+	if !isStruct(t) {
+		if name == "" {
+			return fields
+		}
+
+		return append(fields, name)
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if slices.Contains(path, t) {
+		return fields
+	}
+	path = append(path, t)
+	if name != "" {
+		prefix = name + nestedSeparator
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field, _, promoted, skip := fieldName(t.Field(i))
+		if skip {
+			continue
+		}
+		if !promoted {
+			field = prefix + field
+		}
+
+		fields = typeFields(fields, path, prefix, field, t.Field(i).Type)
+	}
+
+	return fields
+}
+
+// setStruct sets the fields of the struct v from values, it reports whether any field is set.
+func setStruct(values map[string]string, prefix string, v reflect.Value) (bool, error) {
+	var set bool
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, promoted, skip := fieldName(t.Field(i))
+		if skip {
+			continue
+		}
+
+		// 未导出的嵌入结构体本身不可设置，但其导出字段可以
+		fv := v.Field(i)
+		if !fv.CanSet() && !(promoted && fv.Kind() == reflect.Struct) {
+			continue
+		}
+
+		var ok bool
+		var err error
+		if promoted {
+			ok, err = setField(values, prefix, "", fv)
+		} else {
+			ok, err = setField(values, prefix, prefix+name, fv)
+		}
+		if err != nil {
+			return false, err
+		}
+		set = set || ok
+	}
+
+	return set, nil
+}
+
+// setField sets the field v named name from values, it reports whether the field is set.
+// A nil pointer is only allocated if any of its fields is in values.
+func setField(values map[string]string, prefix, name string, v reflect.Value) (bool, error) {
+	if v.Kind() == reflect.Pointer {
+		if !v.IsNil() {
+			return setField(values, prefix, name, v.Elem())
+		}
+
+		elem := reflect.New(v.Type().Elem())
+		ok, err := setField(values, prefix, name, elem.Elem())
+		if ok {
+			v.Set(elem)
+		}
+
+		return ok, err
+	}
+
+	// 只有 any 可以存入字符串，其他接口无法从 hash 中还原
+	if isMethodInterface(v.Type()) {
+		return false, fmt.Errorf("redis: field %s of %v is not supported", name, v.Type())
+	}
+
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) && name != "" {
+		value, ok := values[name]
+		if !ok {
+			return false, nil
+		}
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return false, fmt.Errorf("redis: unmarshal field %s: %w", name, err)
+		}
+
+		return true, nil
+	}
+
+	if v.Kind() == reflect.Struct {
+		if name != "" {
+			prefix = name + nestedSeparator
+		}
+
+		return setStruct(values, prefix, v)
+	}
+	if name == "" {
+		return false, nil
+	}
+
+	value, ok := values[name]
+	if !ok {
+		return false, nil
+	}
+
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		v.SetBytes([]byte(value))
+		return true, nil
+	}
+
+	// ConvertBasicType parses the numbers in 64 bits, check the overflow of the narrower types
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("redis: field %s: %w", name, err)
+		}
+		if v.OverflowInt(x) {
+			return false, fmt.Errorf("redis: field %s: %s overflows %v", name, value, v.Type())
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("redis: field %s: %w", name, err)
+		}
+		if v.OverflowUint(x) {
+			return false, fmt.Errorf("redis: field %s: %s overflows %v", name, value, v.Type())
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false, fmt.Errorf("redis: field %s: %w", name, err)
+		}
+		if v.OverflowFloat(x) {
+			return false, fmt.Errorf("redis: field %s: %s overflows %v", name, value, v.Type())
+		}
+		v.SetFloat(x)
+	default:
+		if err := std.ConvertBasicType(value, v.Type(), v); err != nil {
+			return false, fmt.Errorf("redis: field %s: %w", name, err)
+		}
+	}
+
+	return true, nil
+}
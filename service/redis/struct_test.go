@@ -0,0 +1,210 @@
+package redis
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAddress struct {
+	City string
+	Zip  *int `redis:"zip_code"`
+}
+
+type testBase struct {
+	ID int64 `redis:"id"`
+}
+
+type testUser struct {
+	testBase
+	UserName  string
+	Age       uint8
+	Score     float64
+	Active    bool
+	Tags      []string `redis:"-"`
+	Nickname  string   `redis:",omitempty"`
+	Avatar    []byte
+	CreatedAt time.Time
+	Address   testAddress
+	Backup    *testAddress
+	Note      *string
+}
+
+func TestStructArgs(t *testing.T) {
+	zip := 100000
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := testUser{
+		testBase:  testBase{ID: 7},
+		UserName:  "tom",
+		Age:       18,
+		Score:     9.5,
+		Active:    true,
+		Tags:      []string{"a"},
+		Avatar:    []byte("png"),
+		CreatedAt: createdAt,
+		Address:   testAddress{City: "beijing", Zip: &zip},
+	}
+
+	fields := &structFields{}
+	assert.NoError(t, fields.addStruct("", reflect.ValueOf(user)))
+	args := fields.args
+	assert.Equal(t, []string{
+		"id", "7",
+		"user_name", "tom",
+		"age", "18",
+		"score", "9.5",
+		"active", "true",
+		"avatar", "png",
+		"created_at", "2024-01-02T03:04:05Z",
+		"address.city", "beijing",
+		"address.zip_code", "100000",
+	}, args)
+	assert.Equal(t, []string{"nickname", "backup.city", "backup.zip_code", "note"}, fields.deleted)
+
+	values := make(map[string]string)
+	for i := 0; i < len(args); i += 2 {
+		values[args[i]] = args[i+1]
+	}
+
+	var got testUser
+	ok, err := setStruct(values, "", reflect.ValueOf(&got).Elem())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	user.Tags = nil
+	assert.Equal(t, user, got)
+	assert.Nil(t, got.Backup)
+	assert.Nil(t, got.Note)
+}
+
+func TestSetStruct(t *testing.T) {
+	var user testUser
+	_, err := setStruct(map[string]string{"age": "x"}, "", reflect.ValueOf(&user).Elem())
+	assert.Error(t, err)
+
+	ok, err := setStruct(map[string]string{"backup.city": "shanghai", "note": "n"}, "", reflect.ValueOf(&user).Elem())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, &testAddress{City: "shanghai"}, user.Backup)
+	assert.Equal(t, "n", *user.Note)
+
+	err = (&structFields{}).addStruct("", reflect.ValueOf(struct{ M map[string]int }{}))
+	assert.Error(t, err)
+}
+
+func TestSetStructOverflow(t *testing.T) {
+	var v struct {
+		Small int8
+		Tiny  uint8
+		Big   int64
+	}
+	rv := reflect.ValueOf(&v).Elem()
+
+	_, err := setStruct(map[string]string{"small": "128"}, "", rv)
+	assert.ErrorContains(t, err, "overflows int8")
+	_, err = setStruct(map[string]string{"tiny": "256"}, "", rv)
+	assert.ErrorContains(t, err, "overflows uint8")
+	_, err = setStruct(map[string]string{"tiny": "-1"}, "", rv)
+	assert.Error(t, err)
+
+	var f struct{ F float32 }
+	_, err = setStruct(map[string]string{"f": "1e300"}, "", reflect.ValueOf(&f).Elem())
+	assert.ErrorContains(t, err, "overflows float32")
+	_, err = setStruct(map[string]string{"f": "1.5"}, "", reflect.ValueOf(&f).Elem())
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1.5), f.F)
+
+	ok, err := setStruct(map[string]string{"small": "-128", "tiny": "255", "big": "9223372036854775807"}, "", rv)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int8(-128), v.Small)
+	assert.Equal(t, uint8(255), v.Tiny)
+	assert.Equal(t, int64(9223372036854775807), v.Big)
+}
+
+type testStringer struct{}
+
+func (testStringer) String() string { return "s" }
+
+func TestStructInterface(t *testing.T) {
+	// any 字段可以存取字符串
+	var a struct{ A any }
+	a.A = 1
+	fields := &structFields{}
+	assert.NoError(t, fields.addStruct("", reflect.ValueOf(a)))
+	assert.Equal(t, []string{"a", "1"}, fields.args)
+	_, err := setStruct(map[string]string{"a": "1"}, "", reflect.ValueOf(&a).Elem())
+	assert.NoError(t, err)
+	assert.Equal(t, "1", a.A)
+
+	// 带方法的接口无法还原，存取都报错而不是 panic
+	var v struct {
+		S fmt.Stringer `redis:"s"`
+	}
+	v.S = testStringer{}
+	assert.ErrorContains(t, (&structFields{}).addStruct("", reflect.ValueOf(v)), "not supported")
+	v.S = nil
+	assert.ErrorContains(t, (&structFields{}).addStruct("", reflect.ValueOf(v)), "not supported")
+	_, err = setStruct(map[string]string{"s": "x"}, "", reflect.ValueOf(&v).Elem())
+	assert.ErrorContains(t, err, "not supported")
+}
+
+type testNode struct {
+	Value string
+	Next  *testNode
+}
+
+func TestTypeFields(t *testing.T) {
+	// 递归类型只展开一层
+	fields := typeFields(nil, nil, "", "node", reflect.TypeFor[*testNode]())
+	assert.Equal(t, []string{"node.value"}, fields)
+
+	fields = typeFields(nil, nil, "", "", reflect.TypeFor[testUser]())
+	assert.Contains(t, fields, "id")
+	assert.Contains(t, fields, "created_at")
+	assert.Contains(t, fields, "backup.zip_code")
+	assert.NotContains(t, fields, "tags")
+}
+
+func TestPool_HSetStruct(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	zip := 100000
+	note := "n"
+	user := testUser{
+		testBase: testBase{ID: 7},
+		UserName: "tom",
+		Nickname: "tommy",
+		Avatar:   []byte("png"),
+		Address:  testAddress{City: "beijing", Zip: &zip},
+		Backup:   &testAddress{City: "shanghai"},
+		Note:     &note,
+	}
+	assert.NoError(t, p.HSetStruct("user", &user))
+	s.HSet("user", "extra", "kept")
+
+	var got testUser
+	assert.NoError(t, p.HGetStruct("user", &got))
+	assert.Equal(t, user, got)
+
+	// 置空的指针及 omitempty 的零值从 hash 中删除，未映射的字段保留
+	user.Nickname = ""
+	user.Backup = nil
+	user.Note = nil
+	user.Address.Zip = nil
+	assert.NoError(t, p.HSetStruct("user", user))
+	for _, field := range []string{"nickname", "backup.city", "backup.zip_code", "note", "address.zip_code"} {
+		assert.Equal(t, "", s.HGet("user", field), field)
+	}
+	assert.Equal(t, "kept", s.HGet("user", "extra"))
+
+	got = testUser{}
+	assert.NoError(t, p.HGetStruct("user", &got))
+	assert.Equal(t, user, got)
+
+	assert.ErrorIs(t, p.HGetStruct("missing", &got), ErrNil)
+	assert.Equal(t, errNotStruct, p.HSetStruct("user", "not a struct"))
+}
@@ -0,0 +1,132 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var (
+	// MaxTxRetry is the max times Watch retries the transaction on optimistic lock conflicts.
+	MaxTxRetry = 3
+
+	// ErrTxFailed is returned when EXEC is aborted because a watched key was modified.
+	ErrTxFailed = errors.New("redis: transaction failed, watched keys modified")
+)
+
+// Tx is an optimistic lock transaction bound to a connection which has watched the keys.
+type Tx struct {
+	ctx    context.Context
+	pool   *Pool
+	server int
+	conn   redis.Conn
+	cmds   []*Cmd
+}
+
+// Do executes a command immediately, it is used to read the watched values before queueing commands.
+func (tx *Tx) Do(cmdStr string, args ...any) (any, error) {
+	info := tx.pool.beforeCmd(tx.ctx, cmdStr, args, nil, tx.server)
+	reply, err := redis.DoContext(tx.conn, tx.ctx, cmdStr, args...)
+	tx.pool.afterCmd(info, err)
+
+	return reply, err
+}
+
+// Queue queues a command executed in MULTI/EXEC after the Watch callback returns,
+// the returned Cmd holds the reply after the transaction is committed.
+func (tx *Tx) Queue(cmdStr string, args ...any) *Cmd {
+	cmd := &Cmd{name: cmdStr, args: args}
+	tx.cmds = append(tx.cmds, cmd)
+
+	return cmd
+}
+
+// Watch watches keys and calls fn to read values and queue commands, then commits the queued commands with MULTI/EXEC.
+// The transaction is retried up to MaxTxRetry times when a watched key is modified by others,
+// ErrTxFailed is returned if it still conflicts, an error returned by fn aborts the transaction.
+func (p *Pool) Watch(ctx context.Context, keys []string, fn func(tx *Tx) error) error {
+	if len(keys) == 0 {
+		return errors.New("redis: watch keys is empty")
+	}
+
+	for i := 0; i <= MaxTxRetry; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := p.watch(ctx, keys, fn)
+		if !errors.Is(err, ErrTxFailed) {
+			return err
+		}
+	}
+
+	return ErrTxFailed
+}
+
+func (p *Pool) watch(ctx context.Context, keys []string, fn func(tx *Tx) error) error {
+	pool, index, err := p.activePool()
+	if err != nil {
+		return err
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+
+	tx := &Tx{ctx: ctx, pool: p, server: index, conn: conn}
+	_, err = tx.Do("WATCH", redis.Args{}.AddFlat(keys)...)
+	p.feedback(err)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil || len(tx.cmds) == 0 {
+		_, _ = conn.Do("UNWATCH")
+		return err
+	}
+
+	info := p.beforeCmd(ctx, "MULTI", nil, tx.cmds, index)
+	err = execTx(ctx, conn, tx.cmds)
+	p.afterCmd(info, err)
+	p.feedback(err)
+
+	return err
+}
+
+// execTx sends cmds in MULTI/EXEC and assigns the replies to cmds.
+func execTx(ctx context.Context, conn redis.Conn, cmds []*Cmd) error {
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	for _, cmd := range cmds {
+		if err := conn.Send(cmd.name, cmd.args...); err != nil {
+			setCmdsErr(cmds, err)
+			return err
+		}
+	}
+
+	replies, err := redis.Values(redis.DoContext(conn, ctx, "EXEC"))
+	if err != nil {
+		// EXEC 返回 nil 表示监视的 key 已被修改，事务未执行
+		if errors.Is(err, redis.ErrNil) {
+			err = ErrTxFailed
+		}
+		setCmdsErr(cmds, err)
+
+		return err
+	}
+
+	if len(replies) != len(cmds) {
+		setCmdsErr(cmds, WrongAnswer)
+		return WrongAnswer
+	}
+
+	for i, cmd := range cmds {
+		cmd.reply = replies[i]
+		if e, ok := replies[i].(redis.Error); ok {
+			cmd.reply, cmd.err = nil, e
+		}
+	}
+
+	return firstCmdErr(cmds)
+}
@@ -0,0 +1,153 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordConn records the commands sent on a connection.
+type recordConn struct {
+	redis.Conn
+	cmds *[]string
+}
+
+func (c recordConn) Do(cmd string, args ...any) (any, error) {
+	// the pool flushes the connection with an empty command
+	if cmd != "" {
+		*c.cmds = append(*c.cmds, cmd)
+	}
+	return c.Conn.Do(cmd, args...)
+}
+
+func (c recordConn) DoContext(ctx context.Context, cmd string, args ...any) (any, error) {
+	*c.cmds = append(*c.cmds, cmd)
+	return redis.DoContext(c.Conn, ctx, cmd, args...)
+}
+
+func (c recordConn) ReceiveContext(ctx context.Context) (any, error) {
+	return redis.ReceiveContext(c.Conn, ctx)
+}
+
+func (c recordConn) Send(cmd string, args ...any) error {
+	*c.cmds = append(*c.cmds, cmd)
+	return c.Conn.Send(cmd, args...)
+}
+
+func TestPool_Watch(t *testing.T) {
+	p, s := newMiniredisPool(t)
+	assert.NoError(t, s.Set("counter", "1"))
+
+	var get, incr, set *Cmd
+	err := p.Watch(context.Background(), []string{"counter"}, func(tx *Tx) error {
+		n, err := redis.Int64(tx.Do("GET", "counter"))
+		if err != nil {
+			return err
+		}
+
+		get = tx.Queue("GET", "counter")
+		incr = tx.Queue("INCRBY", "counter", n)
+		set = tx.Queue("SET", "flag", "on")
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// 回复按顺序分配给排队的命令
+	v, err := get.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", v)
+	n, err := incr.Int64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	v, err = set.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", v)
+	s.CheckGet(t, "counter", "2")
+}
+
+func TestPool_WatchReplyError(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	var incr, set *Cmd
+	err := p.Watch(context.Background(), []string{"key"}, func(tx *Tx) error {
+		incr = tx.Queue("INCR", "key")
+		set = tx.Queue("SET", "flag", "on")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, incr.Err())
+
+	assert.NoError(t, s.Set("key", "not a number"))
+	err = p.Watch(context.Background(), []string{"key"}, func(tx *Tx) error {
+		incr = tx.Queue("INCR", "key")
+		set = tx.Queue("SET", "flag", "off")
+		return nil
+	})
+	var replyErr redis.Error
+	assert.ErrorAs(t, err, &replyErr)
+	assert.Equal(t, err, incr.Err())
+	assert.NoError(t, set.Err())
+	s.CheckGet(t, "flag", "off")
+}
+
+func TestPool_WatchRetry(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	// 第一次执行时其他客户端修改了监视的 key，事务重试后成功
+	attempts := 0
+	err := p.Watch(context.Background(), []string{"key"}, func(tx *Tx) error {
+		attempts++
+		if attempts == 1 {
+			assert.NoError(t, s.Set("key", "other"))
+		}
+
+		tx.Queue("SET", "key", "mine")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	s.CheckGet(t, "key", "mine")
+
+	attempts = 0
+	err = p.Watch(context.Background(), []string{"key"}, func(tx *Tx) error {
+		attempts++
+		assert.NoError(t, s.Set("key", "other"))
+
+		tx.Queue("SET", "key", "mine")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrTxFailed)
+	assert.Equal(t, MaxTxRetry+1, attempts)
+	s.CheckGet(t, "key", "other")
+}
+
+func TestPool_WatchCallbackError(t *testing.T) {
+	p, s := newMiniredisPool(t)
+
+	var cmds []string
+	dial := p.pools[0].Dial
+	p.pools[0].Dial = func() (redis.Conn, error) {
+		conn, err := dial()
+		if err != nil {
+			return nil, err
+		}
+
+		return recordConn{Conn: conn, cmds: &cmds}, nil
+	}
+
+	fnErr := errors.New("abort")
+	err := p.Watch(context.Background(), []string{"key"}, func(tx *Tx) error {
+		if _, err := tx.Do("GET", "key"); err != nil {
+			return err
+		}
+
+		tx.Queue("SET", "key", "value")
+		return fnErr
+	})
+	assert.Equal(t, fnErr, err)
+	assert.Equal(t, []string{"WATCH", "GET", "UNWATCH"}, cmds)
+	assert.False(t, s.Exists("key"))
+}
@@ -0,0 +1,194 @@
+package redis
+
+import (
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Z is a member of a sorted set with its score.
+type Z struct {
+	Member string
+	Score  float64
+}
+
+// ZAddArgs are the options of ZAdd.
+type ZAddArgs struct {
+	// NX only adds new members, XX only updates existing members.
+	NX bool
+	XX bool
+	// GT only updates the score if the new one is greater, LT if it is less, new members are still added.
+	GT bool
+	LT bool
+	// CH counts the updated members in the result besides the added ones.
+	CH bool
+}
+
+// ZRangeArgs are the arguments of ZRange and ZRangeWithScores.
+type ZRangeArgs struct {
+	Key string
+	// Start and Stop are ranks by default, scores with ByScore, e.g. "(1" and "+inf",
+	// and lexicographical ranges with ByLex, e.g. "[a" and "+".
+	Start any
+	Stop  any
+	// ByScore and ByLex select the type of the range.
+	ByScore bool
+	ByLex   bool
+	// Rev returns the members from the highest to the lowest, Start is the higher end with ByScore and ByLex.
+	Rev bool
+	// Offset and Count limit the result if Count is not zero, only with ByScore and ByLex, a negative Count returns all from Offset.
+	Offset int64
+	Count  int64
+}
+
+func (a *ZRangeArgs) args(withScores bool) (redis.Args, error) {
+	if a.ByScore && a.ByLex {
+		return nil, errors.New("redis: ZRANGE with both BYSCORE and BYLEX")
+	}
+	if a.Count != 0 && !a.ByScore && !a.ByLex {
+		return nil, errors.New("redis: ZRANGE LIMIT requires BYSCORE or BYLEX")
+	}
+	if withScores && a.ByLex {
+		return nil, errors.New("redis: ZRANGE WITHSCORES is not supported with BYLEX")
+	}
+
+	args := redis.Args{}.Add(a.Key, a.Start, a.Stop)
+	switch {
+	case a.ByScore:
+		args = args.Add("BYSCORE")
+	case a.ByLex:
+		args = args.Add("BYLEX")
+	}
+	if a.Rev {
+		args = args.Add("REV")
+	}
+	if a.Count != 0 {
+		args = args.Add("LIMIT", a.Offset, a.Count)
+	}
+	if withScores {
+		args = args.Add("WITHSCORES")
+	}
+
+	return args, nil
+}
+
+// ZAdd adds the members to the sorted set or updates their scores, args may be nil,
+// it returns the number of added members, plus the updated ones with CH.
+func (p *Pool) ZAdd(key string, args *ZAddArgs, members ...Z) (int64, error) {
+	cmdArgs := redis.Args{}.Add(key)
+	if args != nil {
+		if args.NX {
+			cmdArgs = cmdArgs.Add("NX")
+		}
+		if args.XX {
+			cmdArgs = cmdArgs.Add("XX")
+		}
+		if args.GT {
+			cmdArgs = cmdArgs.Add("GT")
+		}
+		if args.LT {
+			cmdArgs = cmdArgs.Add("LT")
+		}
+		if args.CH {
+			cmdArgs = cmdArgs.Add("CH")
+		}
+	}
+	for _, m := range members {
+		cmdArgs = cmdArgs.Add(m.Score, m.Member)
+	}
+
+	return redis.Int64(p.Do("ZADD", cmdArgs...))
+}
+
+// ZIncrBy increments the score of the member and returns the new score, the member is added if it does not exist.
+func (p *Pool) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	return redis.Float64(p.Do("ZINCRBY", key, increment, member))
+}
+
+// ZRange returns the members in the range.
+func (p *Pool) ZRange(a *ZRangeArgs) ([]string, error) {
+	args, err := a.args(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return redis.Strings(p.Do("ZRANGE", args...))
+}
+
+// ZRangeWithScores returns the members in the range with their scores, it does not support ByLex.
+func (p *Pool) ZRangeWithScores(a *ZRangeArgs) ([]Z, error) {
+	args, err := a.args(true)
+	if err != nil {
+		return nil, err
+	}
+
+	return zSlice(p.Do("ZRANGE", args...))
+}
+
+// ZRem removes the members from the sorted set, it returns the number of removed members.
+func (p *Pool) ZRem(key string, members ...string) (int64, error) {
+	return redis.Int64(p.Do("ZREM", redis.Args{}.Add(key).AddFlat(members)...))
+}
+
+// ZRank returns the rank of the member ordered from the lowest score, ErrNil is returned if the member does not exist.
+func (p *Pool) ZRank(key, member string) (int64, error) {
+	return redis.Int64(p.Do("ZRANK", key, member))
+}
+
+// ZRevRank returns the rank of the member ordered from the highest score, ErrNil is returned if the member does not exist.
+func (p *Pool) ZRevRank(key, member string) (int64, error) {
+	return redis.Int64(p.Do("ZREVRANK", key, member))
+}
+
+// ZScore returns the score of the member, ErrNil is returned if the member does not exist.
+func (p *Pool) ZScore(key, member string) (float64, error) {
+	return redis.Float64(p.Do("ZSCORE", key, member))
+}
+
+// ZCard returns the number of members of the sorted set.
+func (p *Pool) ZCard(key string) (int64, error) {
+	return redis.Int64(p.Do("ZCARD", key))
+}
+
+// ZCount returns the number of members with scores between min and max, e.g. "-inf" and "(10".
+func (p *Pool) ZCount(key string, min, max any) (int64, error) {
+	return redis.Int64(p.Do("ZCOUNT", key, min, max))
+}
+
+// ZPopMin removes and returns at most count members with the lowest scores.
+func (p *Pool) ZPopMin(key string, count int64) ([]Z, error) {
+	return zSlice(p.Do("ZPOPMIN", key, count))
+}
+
+// ZPopMax removes and returns at most count members with the highest scores.
+func (p *Pool) ZPopMax(key string, count int64) ([]Z, error) {
+	return zSlice(p.Do("ZPOPMAX", key, count))
+}
+
+// zSlice converts a reply of members and scores.
+func zSlice(reply any, err error) ([]Z, error) {
+	elems, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems)%2 != 0 {
+		return nil, WrongAnswer
+	}
+
+	zs := make([]Z, 0, len(elems)/2)
+	for i := 0; i < len(elems); i += 2 {
+		member, err := redis.String(elems[i], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		score, err := redis.Float64(elems[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		zs = append(zs, Z{Member: member, Score: score})
+	}
+
+	return zs, nil
+}
@@ -0,0 +1,139 @@
+package redis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZRangeArgs(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       ZRangeArgs
+		withScores bool
+		want       redis.Args
+		wantErr    bool
+	}{
+		{
+			name: "rank",
+			args: ZRangeArgs{Key: "board", Start: 0, Stop: -1},
+			want: redis.Args{"board", 0, -1},
+		},
+		{
+			name:       "score rev limit",
+			args:       ZRangeArgs{Key: "board", Start: "+inf", Stop: "(10", ByScore: true, Rev: true, Offset: 5, Count: 10},
+			withScores: true,
+			want:       redis.Args{"board", "+inf", "(10", "BYSCORE", "REV", "LIMIT", int64(5), int64(10), "WITHSCORES"},
+		},
+		{
+			name: "lex",
+			args: ZRangeArgs{Key: "names", Start: "[a", Stop: "+", ByLex: true},
+			want: redis.Args{"names", "[a", "+", "BYLEX"},
+		},
+		{
+			name:    "score and lex",
+			args:    ZRangeArgs{Key: "board", ByScore: true, ByLex: true},
+			wantErr: true,
+		},
+		{
+			name:    "limit by rank",
+			args:    ZRangeArgs{Key: "board", Start: 0, Stop: -1, Count: 10},
+			wantErr: true,
+		},
+		{
+			name:       "lex with scores",
+			args:       ZRangeArgs{Key: "names", Start: "-", Stop: "+", ByLex: true},
+			withScores: true,
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := tc.args.args(tc.withScores)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, args)
+		})
+	}
+}
+
+func TestZSlice(t *testing.T) {
+	zs, err := zSlice([]any{[]byte("a"), []byte("1.5"), []byte("b"), []byte("inf")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Z{{Member: "a", Score: 1.5}, {Member: "b", Score: math.Inf(1)}}, zs)
+
+	_, err = zSlice([]any{[]byte("a")}, nil)
+	assert.Equal(t, WrongAnswer, err)
+}
+
+func TestPool_ZSet(t *testing.T) {
+	p, _ := newMiniredisPool(t)
+
+	n, err := p.ZAdd("board", nil, Z{Member: "a", Score: 1}, Z{Member: "b", Score: 2}, Z{Member: "c", Score: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	// GT 只提高分数，CH 统计更新的成员
+	n, err = p.ZAdd("board", &ZAddArgs{GT: true, CH: true}, Z{Member: "a", Score: 0}, Z{Member: "b", Score: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = p.ZAdd("board", &ZAddArgs{NX: true}, Z{Member: "a", Score: 10}, Z{Member: "d", Score: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	score, err := p.ZIncrBy("board", 0.5, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, score)
+
+	members, err := p.ZRange(&ZRangeArgs{Key: "board", Start: 0, Stop: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c", "d", "b"}, members)
+
+	zs, err := p.ZRangeWithScores(&ZRangeArgs{Key: "board", Start: "+inf", Stop: "(3", ByScore: true, Rev: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []Z{{Member: "b", Score: 5}, {Member: "d", Score: 4}}, zs)
+
+	rank, err := p.ZRank("board", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rank)
+	rank, err = p.ZRevRank("board", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rank)
+	_, err = p.ZRank("board", "missing")
+	assert.ErrorIs(t, err, ErrNil)
+
+	score, err = p.ZScore("board", "d")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(4), score)
+	_, err = p.ZScore("board", "missing")
+	assert.ErrorIs(t, err, ErrNil)
+
+	count, err := p.ZCount("board", "-inf", "(4")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	zs, err = p.ZPopMin("board", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Z{{Member: "a", Score: 1.5}, {Member: "c", Score: 3}}, zs)
+	zs, err = p.ZPopMax("board", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Z{{Member: "b", Score: 5}}, zs)
+
+	n, err = p.ZRem("board", "d", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	card, err := p.ZCard("board")
+	assert.NoError(t, err)
+	assert.Zero(t, card)
+
+	zs, err = p.ZPopMin("board", 1)
+	assert.NoError(t, err)
+	assert.Empty(t, zs)
+}